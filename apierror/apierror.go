@@ -0,0 +1,238 @@
+// package apierror writes JSON:API error documents with stable error
+// codes, so that the clients could switch on the code rather than on
+// the message
+package apierror
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Class is a kind of error with a stable code and http status
+type Class struct {
+	Code   string
+	Status int
+	Title  string
+}
+
+// Error is an instance of a Class with the details of the failure
+type Error struct {
+	*Class
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+// New returns an error of the class with a formatted detail
+func (c *Class) New(format string, args ...interface{}) *Error {
+	return &Error{Class: c, Detail: fmt.Sprintf(format, args...)}
+}
+
+// The catalogue of errors, the codes are part of the api and should
+// not be changed
+var (
+	ErrMissingParam = &Class{
+		Code:   "missing_param",
+		Status: http.StatusBadRequest,
+		Title:  "Missing parameter",
+	}
+	ErrInvalidParam = &Class{
+		Code:   "invalid_param",
+		Status: http.StatusBadRequest,
+		Title:  "Invalid parameter",
+	}
+	ErrInsecureScheme = &Class{
+		Code:   "insecure_scheme",
+		Status: http.StatusBadRequest,
+		Title:  "Request is not made over https",
+	}
+	ErrRedirectNotAllowed = &Class{
+		Code:   "redirect_not_allowed",
+		Status: http.StatusBadRequest,
+		Title:  "Redirect url is not registered for the client",
+	}
+	ErrInvalidScope = &Class{
+		Code:   "invalid_scope",
+		Status: http.StatusBadRequest,
+		Title:  "Scope is not allowed for the client",
+	}
+	ErrUnknownClient = &Class{
+		Code:   "unknown_client",
+		Status: http.StatusUnauthorized,
+		Title:  "Client is not registered",
+	}
+	ErrInvalidToken = &Class{
+		Code:   "invalid_token",
+		Status: http.StatusUnauthorized,
+		Title:  "Token is missing or invalid",
+	}
+	ErrIdentityNotFound = &Class{
+		Code:   "identity_not_found",
+		Status: http.StatusUnauthorized,
+		Title:  "Identity is not registered",
+	}
+	ErrUserNotFound = &Class{
+		Code:   "user_not_found",
+		Status: http.StatusUnauthorized,
+		Title:  "User is not registered",
+	}
+	ErrClientCertRequired = &Class{
+		Code:   "client_cert_required",
+		Status: http.StatusForbidden,
+		Title:  "Verified client certificate is required",
+	}
+	ErrProviderNotAllowed = &Class{
+		Code:   "provider_not_allowed",
+		Status: http.StatusForbidden,
+		Title:  "Provider is not allowed for the client",
+	}
+	ErrMissingRole = &Class{
+		Code:   "missing_role",
+		Status: http.StatusForbidden,
+		Title:  "User does not have a role required by the client",
+	}
+	ErrAudienceNotAllowed = &Class{
+		Code:   "audience_not_allowed",
+		Status: http.StatusForbidden,
+		Title:  "Token is not issued for the resource",
+	}
+	ErrInsufficientScope = &Class{
+		Code:   "insufficient_scope",
+		Status: http.StatusForbidden,
+		Title:  "Token does not have a scope required by the resource",
+	}
+	ErrImpersonationNotAllowed = &Class{
+		Code:   "impersonation_not_allowed",
+		Status: http.StatusForbidden,
+		Title:  "Impersonation is not allowed",
+	}
+	ErrTokenNotFound = &Class{
+		Code:   "token_not_found",
+		Status: http.StatusNotFound,
+		Title:  "Personal access token not found",
+	}
+	ErrSessionNotFound = &Class{
+		Code:   "session_not_found",
+		Status: http.StatusNotFound,
+		Title:  "Session is not found",
+	}
+	ErrDeviceCodeNotFound = &Class{
+		Code:   "device_code_not_found",
+		Status: http.StatusNotFound,
+		Title:  "Device code is unknown or expired",
+	}
+	ErrAuthorizationNotFound = &Class{
+		Code:   "authorization_request_not_found",
+		Status: http.StatusNotFound,
+		Title:  "Authorization request is unknown or expired",
+	}
+	ErrNotFound = &Class{
+		Code:   "not_found",
+		Status: http.StatusNotFound,
+		Title:  "Resource not found",
+	}
+	ErrMethodNotAllowed = &Class{
+		Code:   "method_not_allowed",
+		Status: http.StatusMethodNotAllowed,
+		Title:  "Method not allowed",
+	}
+	ErrRateLimited = &Class{
+		Code:   "rate_limited",
+		Status: http.StatusTooManyRequests,
+		Title:  "Too many requests",
+	}
+	ErrLockedOut = &Class{
+		Code:   "locked_out",
+		Status: http.StatusTooManyRequests,
+		Title:  "Too many failed logins",
+	}
+	ErrProviderExchange = &Class{
+		Code:   "provider_exchange_failed",
+		Status: http.StatusBadGateway,
+		Title:  "Unable to exchange code with provider",
+	}
+	ErrProviderProfile = &Class{
+		Code:   "provider_profile_failed",
+		Status: http.StatusBadGateway,
+		Title:  "Unable to fetch user profile from provider",
+	}
+	ErrMessaging = &Class{
+		Code:   "messaging_error",
+		Status: http.StatusBadGateway,
+		Title:  "Error in messaging with user services",
+	}
+	ErrStore = &Class{
+		Code:   "store_error",
+		Status: http.StatusInternalServerError,
+		Title:  "Error in accessing the token store",
+	}
+	ErrUnavailable = &Class{
+		Code:   "unavailable",
+		Status: http.StatusServiceUnavailable,
+		Title:  "Service is unavailable",
+	}
+	ErrRequestContext = &Class{
+		Code:   "request_context",
+		Status: http.StatusInternalServerError,
+		Title:  "Missing value in request context",
+	}
+	ErrTokenSigning = &Class{
+		Code:   "token_signing_failed",
+		Status: http.StatusInternalServerError,
+		Title:  "Unable to sign token",
+	}
+	ErrJSONEncoding = &Class{
+		Code:   "json_encoding_failed",
+		Status: http.StatusInternalServerError,
+		Title:  "Error in encoding or decoding json",
+	}
+)
+
+type errorObject struct {
+	Status string            `json:"status"`
+	Code   string            `json:"code"`
+	Title  string            `json:"title"`
+	Detail string            `json:"detail,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+}
+
+type errorDocument struct {
+	Errors []*errorObject `json:"errors"`
+}
+
+// JSONAPIError writes the error as a JSON:API error document with
+// the request id, if any, in the meta
+func JSONAPIError(w http.ResponseWriter, r *http.Request, err *Error) {
+	obj := &errorObject{
+		Status: strconv.Itoa(err.Status),
+		Code:   err.Code,
+		Title:  err.Title,
+		Detail: err.Detail,
+	}
+	if reqID := middleware.GetReqID(r.Context()); len(reqID) > 0 {
+		obj.Meta = map[string]string{"request_id": reqID}
+	}
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(err.Status)
+	if err := json.NewEncoder(w).Encode(&errorDocument{Errors: []*errorObject{obj}}); err != nil {
+		log.Printf("unable to write error document %s\n", err)
+	}
+}
+
+// NotFound is a http.HandlerFunc for routes that do not exist
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	JSONAPIError(w, r, ErrNotFound.New("no route for %s", r.URL.Path))
+}
+
+// MethodNotAllowed is a http.HandlerFunc for routes that do not
+// support the method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	JSONAPIError(w, r, ErrMethodNotAllowed.New("method %s is not allowed for %s", r.Method, r.URL.Path))
+}
@@ -0,0 +1,68 @@
+package apierror
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// OAuthClass is an error of the oauth2 endpoints, they are written in
+// the format of RFC 6749 rather than JSON:API as the oauth2 clients
+// expect it
+type OAuthClass struct {
+	Code   string
+	Status int
+}
+
+// OAuthError is an instance of OAuthClass with the description
+// of the failure
+type OAuthError struct {
+	*OAuthClass
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// New returns an error of the class with a formatted description
+func (c *OAuthClass) New(format string, args ...interface{}) *OAuthError {
+	return &OAuthError{OAuthClass: c, Description: fmt.Sprintf(format, args...)}
+}
+
+// The error codes of RFC 6749, RFC 8628 and RFC 8693
+var (
+	OAuthAuthorizationPending = &OAuthClass{"authorization_pending", http.StatusBadRequest}
+	OAuthSlowDown             = &OAuthClass{"slow_down", http.StatusBadRequest}
+	OAuthAccessDenied         = &OAuthClass{"access_denied", http.StatusBadRequest}
+	OAuthExpiredToken         = &OAuthClass{"expired_token", http.StatusBadRequest}
+	OAuthInvalidRequest       = &OAuthClass{"invalid_request", http.StatusBadRequest}
+	OAuthInvalidClient        = &OAuthClass{"invalid_client", http.StatusUnauthorized}
+	OAuthInvalidGrant         = &OAuthClass{"invalid_grant", http.StatusBadRequest}
+	OAuthUnauthorizedClient   = &OAuthClass{"unauthorized_client", http.StatusBadRequest}
+	OAuthUnsupportedGrantType = &OAuthClass{"unsupported_grant_type", http.StatusBadRequest}
+	OAuthUnsupportedResponse  = &OAuthClass{"unsupported_response_type", http.StatusBadRequest}
+	OAuthInvalidScope         = &OAuthClass{"invalid_scope", http.StatusBadRequest}
+	OAuthInvalidTarget        = &OAuthClass{"invalid_target", http.StatusBadRequest}
+	OAuthServerError          = &OAuthClass{"server_error", http.StatusInternalServerError}
+)
+
+type oauthDocument struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// WriteOAuthError writes the error as a RFC 6749 error response
+func WriteOAuthError(w http.ResponseWriter, r *http.Request, err *OAuthError) {
+	if err.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="authserver"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(err.Status)
+	doc := &oauthDocument{Error: err.Code, Description: err.Description}
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		log.Printf("unable to write oauth error %s\n", err)
+	}
+}
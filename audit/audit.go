@@ -0,0 +1,117 @@
+// package audit keeps an append-only record of the authentication
+// decisions of the server, separate from the request logs
+package audit
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Outcomes of an audited decision
+const (
+	Issued    = "issued"
+	Denied    = "denied"
+	Revoked   = "revoked"
+	Validated = "validated"
+)
+
+// Actions that are audited
+const (
+	ActionLogin             = "login"
+	ActionAuthorize         = "authorize"
+	ActionClientCredentials = "client_credentials"
+	ActionPersonalToken     = "personal_token"
+	ActionDevice            = "device_code"
+	ActionAuthorizationCode = "authorization_code"
+	ActionRefreshToken      = "refresh_token"
+	ActionTokenExchange     = "token_exchange"
+	ActionImpersonation     = "impersonation"
+	ActionSession           = "session"
+)
+
+// Event is a single audited decision
+type Event struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+	UserID    int64     `json:"user_id,omitempty"`
+	ActorID   int64     `json:"actor_id,omitempty"`
+	Identity  string    `json:"identity,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	ClientID  string    `json:"client_id,omitempty"`
+	TokenID   string    `json:"token_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// NewEvent returns an event with the details of the request filled in
+func NewEvent(r *http.Request, action, outcome string) *Event {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return &Event{
+		Time:      time.Now().UTC(),
+		Action:    action,
+		Outcome:   outcome,
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// Sink stores the audit events
+type Sink interface {
+	Write(*Event) error
+	Close() error
+}
+
+// Auditor sends every event to all of its sinks. A nil Auditor
+// discards the events.
+type Auditor struct {
+	sinks []Sink
+}
+
+// NewAuditor returns an Auditor that writes to the sinks
+func NewAuditor(sinks ...Sink) *Auditor {
+	return &Auditor{sinks: sinks}
+}
+
+// Enabled tells if the events are written anywhere
+func (a *Auditor) Enabled() bool {
+	return a != nil && len(a.sinks) > 0
+}
+
+// Record writes the event to the sinks, a failed write is
+// logged and does not stop the other sinks
+func (a *Auditor) Record(e *Event) {
+	if a == nil {
+		return
+	}
+	for _, s := range a.sinks {
+		if err := s.Write(e); err != nil {
+			log.Printf("unable to write audit event %s\n", err)
+		}
+	}
+}
+
+// Close flushes and closes all the sinks
+func (a *Auditor) Close() error {
+	if a == nil {
+		return nil
+	}
+	var cerr error
+	for _, s := range a.sinks {
+		if err := s.Close(); err != nil {
+			cerr = err
+		}
+	}
+	return cerr
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dictyBase/authserver/message"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// JSONSink writes the events as json lines, it is safe for concurrent use
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// NewJSONSink returns a Sink that writes to w
+func NewJSONSink(w io.WriteCloser) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+// FileOptions configures the rotation of the audit file
+type FileOptions struct {
+	// Maximum size in megabytes before the file is rotated
+	MaxSize int
+	// Maximum number of rotated files to keep, 0 keeps all of them
+	MaxBackups int
+	// Maximum days to keep a rotated file, 0 keeps them forever
+	MaxAge int
+}
+
+// NewFileSink returns a Sink that appends json lines to the file and
+// rotates it once it reaches the maximum size
+func NewFileSink(file string, opts *FileOptions) *JSONSink {
+	return NewJSONSink(&lumberjack.Logger{
+		Filename:   file,
+		MaxSize:    opts.MaxSize,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAge,
+		LocalTime:  false,
+	})
+}
+
+// NewStdoutSink returns a Sink that writes json lines to stdout
+func NewStdoutSink() *JSONSink {
+	return NewJSONSink(nopCloser{os.Stdout})
+}
+
+func (s *JSONSink) Write(e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+func (s *JSONSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+// PublishSink publishes the events as json to a subject of the
+// messaging server
+type PublishSink struct {
+	pub     message.Publisher
+	subject string
+}
+
+// NewPublishSink returns a Sink that publishes to the subject
+func NewPublishSink(pub message.Publisher, subject string) *PublishSink {
+	return &PublishSink{pub: pub, subject: subject}
+}
+
+func (s *PublishSink) Write(e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.pub.Publish(s.subject, b)
+}
+
+// Close does nothing, the messaging connection is drained by the server
+func (s *PublishSink) Close() error {
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+// package certs provides tls certificates for the web server
+// that are reloaded when the files change on disk
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader keeps a certificate and key pair in memory and
+// reloads them whenever any of the files are modified
+type Reloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// NewReloader loads the certificate and key pair from the files
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, it satisfies the
+// GetCertificate field of tls.Config
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch checks the files for modification in every interval and reloads
+// them until the stop channel is closed, a nil channel watches for the
+// lifetime of the process. A failed reload is logged and the previous
+// certificate is kept.
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			mt, err := r.lastModified()
+			if err != nil {
+				log.Printf("unable to check tls certificate files %s\n", err)
+				continue
+			}
+			r.mu.RLock()
+			changed := mt.After(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Printf("unable to reload tls certificate %s\n", err)
+				continue
+			}
+			log.Printf("reloaded tls certificate from %s\n", r.certFile)
+		}
+	}
+}
+
+func (r *Reloader) reload() error {
+	mt, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load certificate and key pair %s", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = mt
+	return nil
+}
+
+// Returns the latest modification time of the certificate and key files
+func (r *Reloader) lastModified() (time.Time, error) {
+	var mt time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return mt, err
+		}
+		if info.ModTime().After(mt) {
+			mt = info.ModTime()
+		}
+	}
+	return mt, nil
+}
+
+// ReadCertPool reads the pem encoded certificate authorities from the
+// file for verifying client certificates
+func ReadCertPool(file string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return pool, err
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return pool, fmt.Errorf("no certificate found in %s", file)
+	}
+	return pool, nil
+}
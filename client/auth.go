@@ -0,0 +1,142 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// AssertionType is the client_assertion_type of private_key_jwt
+const AssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// MaxAssertionLifetime is the longest time an assertion could be valid,
+// which also bounds how long its id is kept for detecting replays
+const MaxAssertionLifetime = 5 * time.Minute
+
+// ErrInvalidClient is returned when a client could not be authenticated
+var ErrInvalidClient = errors.New("client authentication failed")
+
+// Authenticate authenticates a confidential client of the oauth2
+// endpoints by either its secret, given with basic authentication or in
+// the form, or by a signed jwt assertion(private_key_jwt). The audience
+// of the assertion should be the url of the endpoint, no assertion is
+// accepted if it is empty.
+func (reg *Registry) Authenticate(r *http.Request, audience string) (*Client, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("%w, unable to parse form %s", ErrInvalidClient, err)
+	}
+	if r.PostForm.Get("client_assertion_type") == AssertionType {
+		if len(audience) == 0 {
+			return nil, fmt.Errorf("%w, client assertions are not accepted without an issuer url", ErrInvalidClient)
+		}
+		return reg.authenticateAssertion(r.PostForm.Get("client_assertion"), audience)
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// the credentials of basic authentication are form encoded
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if len(id) == 0 {
+		return nil, fmt.Errorf("%w, no client credentials are given", ErrInvalidClient)
+	}
+	c, ok := reg.clients[id]
+	if !ok || len(c.SecretHash) == 0 {
+		return nil, fmt.Errorf("%w, client %s does not have a secret", ErrInvalidClient, id)
+	}
+	sum := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(c.SecretHash)) != 1 {
+		return nil, fmt.Errorf("%w, secret of client %s does not match", ErrInvalidClient, id)
+	}
+	return c, nil
+}
+
+// Verifies the jwt assertion with the keys of the client, the issuer and
+// subject is the client id and every assertion could be used only once
+func (reg *Registry) authenticateAssertion(assertion, audience string) (*Client, error) {
+	claims := &jwt.StandardClaims{}
+	var c *Client
+	_, err := jwt.ParseWithClaims(assertion, claims, func(t *jwt.Token) (interface{}, error) {
+		var ok bool
+		c, ok = reg.clients[claims.Subject]
+		if !ok || c.JWKS == nil {
+			return nil, fmt.Errorf("client %s does not have any key", claims.Subject)
+		}
+		kid, _ := t.Header["kid"].(string)
+		k, err := c.JWKS.Key(kid)
+		if err != nil {
+			return nil, err
+		}
+		if len(k.Alg) > 0 && k.Alg != t.Method.Alg() {
+			return nil, fmt.Errorf("key %s is for %s not %s", k.Kid, k.Alg, t.Method.Alg())
+		}
+		return k.PublicKey()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w, invalid client assertion %s", ErrInvalidClient, err)
+	}
+	switch {
+	case claims.Issuer != claims.Subject:
+		return nil, fmt.Errorf("%w, issuer of client assertion is not the client", ErrInvalidClient)
+	case !claims.VerifyAudience(audience, true):
+		return nil, fmt.Errorf("%w, audience of client assertion is not %s", ErrInvalidClient, audience)
+	case claims.ExpiresAt == 0 || len(claims.Id) == 0:
+		return nil, fmt.Errorf("%w, client assertion needs exp and jti", ErrInvalidClient)
+	}
+	exp := time.Unix(claims.ExpiresAt, 0)
+	if time.Until(exp) > MaxAssertionLifetime ||
+		(claims.IssuedAt > 0 && exp.Sub(time.Unix(claims.IssuedAt, 0)) > MaxAssertionLifetime) {
+		return nil, fmt.Errorf("%w, client assertion is valid for longer than %s", ErrInvalidClient, MaxAssertionLifetime)
+	}
+	if !reg.assertions.add(c.ID+":"+claims.Id, exp) {
+		return nil, fmt.Errorf("%w, client assertion %s is already used", ErrInvalidClient, claims.Id)
+	}
+	return c, nil
+}
+
+// Keeps the ids of the assertions until they expire
+type replayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{seen: make(map[string]time.Time)}
+}
+
+// Adds the id, returns false if it is already there
+func (rc *replayCache) add(id string, expires time.Time) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.prune()
+	if exp, ok := rc.seen[id]; ok && time.Now().Before(exp) {
+		return false
+	}
+	rc.seen[id] = expires
+	return true
+}
+
+// removes the expired ids, at most once a minute
+func (rc *replayCache) prune() {
+	now := time.Now()
+	if now.Sub(rc.lastPrune) < time.Minute {
+		return
+	}
+	rc.lastPrune = now
+	for k, exp := range rc.seen {
+		if now.After(exp) {
+			delete(rc.seen, k)
+		}
+	}
+}
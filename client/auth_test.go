@@ -0,0 +1,165 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/dictyBase/authserver/token"
+)
+
+const tokenURL = "https://auth.dictybase.org/oauth/token"
+
+func testRegistry(t *testing.T) (*Registry, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := token.NewJWK(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("secret"))
+	reg := NewRegistry([]*Client{
+		{ID: "stock", SecretHash: hex.EncodeToString(sum[:])},
+		{ID: "orders", JWKS: &token.JWKS{Keys: []*token.JWK{jwk}}},
+	})
+	return reg, key
+}
+
+func assertion(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.StandardClaims) string {
+	s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func formRequest(form url.Values) *http.Request {
+	r := httptest.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func assertionRequest(signed string) *http.Request {
+	return formRequest(url.Values{
+		"client_assertion_type": {AssertionType},
+		"client_assertion":      {signed},
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	reg, key := testRegistry(t)
+	now := time.Now()
+	valid := func(jti string) jwt.StandardClaims {
+		return jwt.StandardClaims{
+			Issuer:    "orders",
+			Subject:   "orders",
+			Audience:  tokenURL,
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Minute).Unix(),
+		}
+	}
+	basic := formRequest(url.Values{})
+	basic.SetBasicAuth("stock", "secret")
+	wrongBasic := formRequest(url.Values{})
+	wrongBasic.SetBasicAuth("stock", "other")
+	wrongAud := valid("aud")
+	wrongAud.Audience = "https://evil.example.org/oauth/token"
+	wrongIss := valid("iss")
+	wrongIss.Issuer = "stock"
+	noJti := valid("")
+	noExp := valid("exp")
+	noExp.ExpiresAt = 0
+	longExp := valid("long")
+	longExp.ExpiresAt = now.Add(time.Hour).Unix()
+	cases := []struct {
+		name     string
+		r        *http.Request
+		audience string
+		client   string
+	}{
+		{"client_secret_basic", basic, tokenURL, "stock"},
+		{
+			"client_secret_post",
+			formRequest(url.Values{"client_id": {"stock"}, "client_secret": {"secret"}}),
+			tokenURL, "stock",
+		},
+		{"wrong basic secret", wrongBasic, tokenURL, ""},
+		{
+			"wrong post secret",
+			formRequest(url.Values{"client_id": {"stock"}, "client_secret": {"other"}}),
+			tokenURL, "",
+		},
+		{
+			"client without secret",
+			formRequest(url.Values{"client_id": {"orders"}, "client_secret": {"secret"}}),
+			tokenURL, "",
+		},
+		{"no credentials", formRequest(url.Values{}), tokenURL, ""},
+		{
+			"valid assertion",
+			assertionRequest(assertion(t, jwt.SigningMethodES256, key, valid("ok"))),
+			tokenURL, "orders",
+		},
+		{
+			"assertion without audience",
+			assertionRequest(assertion(t, jwt.SigningMethodES256, key, valid("noaud"))),
+			"", "",
+		},
+		{"wrong aud", assertionRequest(assertion(t, jwt.SigningMethodES256, key, wrongAud)), tokenURL, ""},
+		{"wrong iss", assertionRequest(assertion(t, jwt.SigningMethodES256, key, wrongIss)), tokenURL, ""},
+		{
+			"wrong alg",
+			assertionRequest(assertion(t, jwt.SigningMethodHS256, []byte("secret"), valid("alg"))),
+			tokenURL, "",
+		},
+		{"missing jti", assertionRequest(assertion(t, jwt.SigningMethodES256, key, noJti)), tokenURL, ""},
+		{"missing exp", assertionRequest(assertion(t, jwt.SigningMethodES256, key, noExp)), tokenURL, ""},
+		{"long lived", assertionRequest(assertion(t, jwt.SigningMethodES256, key, longExp)), tokenURL, ""},
+	}
+	for _, c := range cases {
+		cl, err := reg.Authenticate(c.r, c.audience)
+		if len(c.client) == 0 {
+			if !errors.Is(err, ErrInvalidClient) {
+				t.Errorf("%s: expected ErrInvalidClient got %v", c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", c.name, err)
+			continue
+		}
+		if cl.ID != c.client {
+			t.Errorf("%s: expected client %s got %s", c.name, c.client, cl.ID)
+		}
+	}
+}
+
+func TestAuthenticateReplay(t *testing.T) {
+	reg, key := testRegistry(t)
+	signed := assertion(t, jwt.SigningMethodES256, key, jwt.StandardClaims{
+		Issuer:    "orders",
+		Subject:   "orders",
+		Audience:  tokenURL,
+		Id:        "once",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	})
+	if _, err := reg.Authenticate(assertionRequest(signed), tokenURL); err != nil {
+		t.Fatalf("unexpected error for the first use %s", err)
+	}
+	if _, err := reg.Authenticate(assertionRequest(signed), tokenURL); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("expected a replayed assertion to be rejected got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+// package client keeps the registry of the applications that are allowed
+// to get tokens, and the token settings of each of them
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/token"
+)
+
+type contextKey string
+
+// String output the details of context key
+func (c contextKey) String() string {
+	return "client context key " + string(c)
+}
+
+var contextKeyClient = contextKey("client")
+
+// Client is an application that gets tokens from the server
+type Client struct {
+	ID   string
+	Name string
+	// Audience of the issued tokens
+	Audience string
+	// Lifetimes of the access and refresh tokens
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Providers the users of the client could login with, any
+	// provider is allowed if it is empty
+	AllowedProviders []string
+	// Redirect urls the client could use, matched exactly
+	RedirectURLs []string
+	// Users need at least one of the roles to get a token, no role
+	// is needed if it is empty
+	RequiredRoles []string
+	// Hex encoded sha256 hash of the secret of a confidential client
+	SecretHash string
+	// Keys of a confidential client that authenticates with
+	// a signed jwt(private_key_jwt)
+	JWKS *token.JWKS
+	// Scopes that could be granted to the client
+	Scopes []string
+	// Audiences of the resources the client could exchange tokens for
+	ExchangeAudiences []string
+}
+
+// IsConfidential checks if the client could authenticate itself
+func (c *Client) IsConfidential() bool {
+	return len(c.SecretHash) > 0 || c.JWKS != nil
+}
+
+// Default returns the settings for tokens of clients that are
+// not registered
+func Default(id string) *Client {
+	return &Client{
+		ID:        id,
+		Audience:  token.DefaultAudience,
+		AccessTTL: token.DefaultTTL,
+	}
+}
+
+// AllowsExchange checks if the client could exchange tokens for the
+// audience of a resource
+func (c *Client) AllowsExchange(audience string) bool {
+	return contains(c.ExchangeAudiences, audience)
+}
+
+// AllowsProvider checks if the users of the client could login
+// with the provider
+func (c *Client) AllowsProvider(provider string) bool {
+	return len(c.AllowedProviders) == 0 || contains(c.AllowedProviders, provider)
+}
+
+// AllowsRedirect checks if the redirect url is registered for the client,
+// a client without any registered url allows any of them
+func (c *Client) AllowsRedirect(url string) bool {
+	return len(c.RedirectURLs) == 0 || contains(c.RedirectURLs, url)
+}
+
+// HasRequiredRole checks if any of the roles is required by the client
+func (c *Client) HasRequiredRole(roles []string) bool {
+	if len(c.RequiredRoles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if contains(c.RequiredRoles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry is the list of clients looked up by their id. An empty
+// registry accepts any client with the default settings.
+type Registry struct {
+	clients map[string]*Client
+	// ids of the used client assertions
+	assertions *replayCache
+}
+
+// NewRegistry returns a registry of the clients
+func NewRegistry(clients []*Client) *Registry {
+	reg := &Registry{
+		clients:    make(map[string]*Client),
+		assertions: newReplayCache(),
+	}
+	for _, c := range clients {
+		reg.clients[c.ID] = c
+	}
+	return reg
+}
+
+// Lookup returns the client with the id, for an empty registry
+// the default settings are returned
+func (reg *Registry) Lookup(id string) (*Client, bool) {
+	if len(reg.clients) == 0 {
+		return Default(id), true
+	}
+	c, ok := reg.clients[id]
+	return c, ok
+}
+
+// Audiences returns the audiences of the tokens issued to the users, the
+// default one and the ones of the registered clients
+func (reg *Registry) Audiences() []string {
+	audiences := []string{token.DefaultAudience}
+	for _, c := range reg.clients {
+		if !contains(audiences, c.Audience) {
+			audiences = append(audiences, c.Audience)
+		}
+	}
+	return audiences
+}
+
+// UsesAssertions tells if any client authenticates with a jwt
+// assertion(private_key_jwt)
+func (reg *Registry) UsesAssertions() bool {
+	for _, c := range reg.clients {
+		if c.JWKS != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware looks up the client_id that is read by ParamsMiddleware,
+// checks the redirect url and the provider and stores the client in
+// the request context
+func (reg *Registry) Middleware(provider string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			oauthConf, ok := middlewares.OauthConfigFromContext(r.Context())
+			if !ok {
+				apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("no oauth config in request context"))
+				return
+			}
+			c, ok := reg.Lookup(oauthConf.ClientID)
+			if !ok {
+				apierror.JSONAPIError(w, r, apierror.ErrUnknownClient.New("client %s is not registered", oauthConf.ClientID))
+				return
+			}
+			if !c.AllowsRedirect(oauthConf.RedirectURL) {
+				apierror.JSONAPIError(
+					w, r,
+					apierror.ErrRedirectNotAllowed.New("redirect url %s is not registered for client %s", oauthConf.RedirectURL, c.ID),
+				)
+				return
+			}
+			if !c.AllowsProvider(provider) {
+				apierror.JSONAPIError(
+					w, r,
+					apierror.ErrProviderNotAllowed.New("provider %s is not allowed for client %s", provider, c.ID),
+				)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(NewContext(r.Context(), c)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// NewContext returns a copy of the context with the client
+func NewContext(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, contextKeyClient, c)
+}
+
+// FromContext returns the client stored by Middleware
+func FromContext(ctx context.Context) (*Client, bool) {
+	c, ok := ctx.Value(contextKeyClient).(*Client)
+	return c, ok
+}
+
+func contains(list []string, v string) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
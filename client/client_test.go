@@ -0,0 +1,63 @@
+package client
+
+import "testing"
+
+func TestHasRequiredRole(t *testing.T) {
+	cases := []struct {
+		name     string
+		required []string
+		roles    []string
+		want     bool
+	}{
+		{"no required role", nil, nil, true},
+		{"no required role with roles", nil, []string{"curator"}, true},
+		{"user without roles", []string{"curator"}, nil, false},
+		{"user with other role", []string{"curator"}, []string{"user"}, false},
+		{"user with required role", []string{"curator", "admin"}, []string{"user", "admin"}, true},
+	}
+	for _, c := range cases {
+		cl := &Client{ID: "test", RequiredRoles: c.required}
+		if got := cl.HasRequiredRole(c.roles); got != c.want {
+			t.Errorf("%s: expected %t got %t", c.name, c.want, got)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	cl := &Client{
+		ID:               "test",
+		AllowedProviders: []string{"google"},
+		RedirectURLs:     []string{"https://example.org/callback"},
+	}
+	if !cl.AllowsProvider("google") || cl.AllowsProvider("orcid") {
+		t.Error("expected only the google provider to be allowed")
+	}
+	if !cl.AllowsRedirect("https://example.org/callback") || cl.AllowsRedirect("https://example.org/callback/") {
+		t.Error("expected the redirect url to be matched exactly")
+	}
+	def := Default("other")
+	if !def.AllowsProvider("orcid") || !def.AllowsRedirect("https://example.com") {
+		t.Error("expected the default client to allow any provider and redirect url")
+	}
+}
+
+func TestAudiences(t *testing.T) {
+	reg := NewRegistry([]*Client{
+		{ID: "web", Audience: "user"},
+		{ID: "stock", Audience: "stockcenter"},
+	})
+	got := reg.Audiences()
+	if len(got) != 2 || got[0] != "user" || got[1] != "stockcenter" {
+		t.Errorf("expected the default and the client audiences got %v", got)
+	}
+}
+
+func TestAllowsExchange(t *testing.T) {
+	cl := &Client{ID: "stock", ExchangeAudiences: []string{"stock-orders"}}
+	if !cl.AllowsExchange("stock-orders") || cl.AllowsExchange("curation") {
+		t.Error("expected only the listed audience to be allowed")
+	}
+	if Default("other").AllowsExchange("stock-orders") {
+		t.Error("expected the default client not to exchange tokens")
+	}
+}
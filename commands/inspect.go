@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dictyBase/authserver/token"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// InspectToken decodes a token, verifies it against the public key or
+// key set and reports why it would be rejected by /authorize
+func InspectToken(c *cli.Context) error {
+	raw, err := readToken(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	keyFunc, err := inspectKeyFunc(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	alg := c.String("algorithm")
+	if len(alg) == 0 && len(c.String("public-key")) > 0 {
+		pubkey, _ := keyFunc(nil)
+		alg, err = token.Algorithm(pubkey)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("unsupported public key %s", err), 2)
+		}
+	}
+	rep, err := token.Inspect(raw, &token.InspectOptions{
+		KeyFunc:   keyFunc,
+		Algorithm: alg,
+		Audience:  c.String("audience"),
+		Issuer:    c.String("issuer"),
+	})
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to decode token %s", err), 2)
+	}
+	if c.String("format") == "json" {
+		enc := json.NewEncoder(c.App.Writer)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			return cli.NewExitError(fmt.Sprintf("unable to encode report %s", err), 2)
+		}
+	} else {
+		printReport(c.App.Writer, rep)
+	}
+	if !rep.Valid {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
+
+// Reads the token from the first argument, or from stdin if
+// it is not given or is -
+func readToken(c *cli.Context) (string, error) {
+	if raw := c.Args().First(); len(raw) > 0 && raw != "-" {
+		return raw, nil
+	}
+	b, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("unable to read token from stdin %s", err)
+	}
+	raw := strings.TrimSpace(string(b))
+	if len(raw) == 0 {
+		return "", fmt.Errorf("no token is given")
+	}
+	return raw, nil
+}
+
+func inspectKeyFunc(c *cli.Context) (func(map[string]interface{}) (interface{}, error), error) {
+	if len(c.String("public-key")) > 0 {
+		public, err := ioutil.ReadFile(c.String("public-key"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read public key %s", err)
+		}
+		pubkey, err := token.ParsePublicKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse public key %s", err)
+		}
+		return func(map[string]interface{}) (interface{}, error) {
+			return pubkey, nil
+		}, nil
+	}
+	if len(c.String("jwks-url")) > 0 {
+		set, err := token.FetchJWKS(c.String("jwks-url"))
+		if err != nil {
+			return nil, err
+		}
+		return func(header map[string]interface{}) (interface{}, error) {
+			kid, _ := header["kid"].(string)
+			k, err := set.Key(kid)
+			if err != nil {
+				return nil, err
+			}
+			return k.PublicKey()
+		}, nil
+	}
+	return nil, nil
+}
+
+func printReport(w io.Writer, rep *token.Report) {
+	fmt.Fprintln(w, "Header:")
+	printMap(w, rep.Header)
+	fmt.Fprintln(w, "Claims:")
+	printMap(w, rep.Claims)
+	fmt.Fprintln(w, "Checks:")
+	for _, c := range rep.Checks {
+		fmt.Fprintf(w, "  [%-4s] %-9s %s\n", c.Status, c.Name, c.Message)
+	}
+	switch {
+	case rep.Valid:
+		fmt.Fprintln(w, "Token is valid")
+	case !rep.Verified && !hasFailure(rep):
+		fmt.Fprintln(w, "Signature is not verified, give a public key or key set to validate the token")
+	default:
+		fmt.Fprintln(w, "Token would be rejected by /authorize")
+	}
+}
+
+func hasFailure(rep *token.Report) bool {
+	for _, c := range rep.Checks {
+		if c.Status == token.CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+func printMap(w io.Writer, m map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b, _ := json.Marshal(m[k])
+		fmt.Fprintf(w, "  %s: %s\n", k, b)
+	}
+}
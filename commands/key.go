@@ -1,64 +1,120 @@
 package commands
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"os"
 
+	"github.com/dictyBase/authserver/token"
 	"gopkg.in/urfave/cli.v1"
 )
 
-// Generate RSA public and private keys in PEM format
+// Output file of generate-keys, the name is given by the flag
+type keyFile struct {
+	flag    string
+	perm    os.FileMode
+	content []byte
+}
+
+// Generate RSA, ECDSA or Ed25519 public and private keys in PEM format,
+// optionally the public key is also written as JWK and JWKS
 func GenerateKeys(c *cli.Context) error {
-	// validate
-	if !c.IsSet("public") {
-		return cli.NewExitError("public key output file is not provided", 2)
-	}
-	if !c.IsSet("private") {
-		return cli.NewExitError("private key output file is not provided", 2)
+	private, err := generateKey(c)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in generating private key %q\n", err), 2)
 	}
-
-	// open files
-	prvWriter, err := os.Create(c.String("private"))
-	defer prvWriter.Close()
+	prvCont, err := x509.MarshalPKCS8PrivateKey(private)
 	if err != nil {
-		return cli.NewExitError(fmt.Sprintf("unable to create private key file %q\n", err), 2)
+		return cli.NewExitError(fmt.Sprintf("unable to marshall private key %q\n", err), 2)
 	}
-	pubWriter, err := os.Create(c.String("public"))
-	defer pubWriter.Close()
+	pubCont, err := x509.MarshalPKIXPublicKey(private.Public())
 	if err != nil {
-		return cli.NewExitError(fmt.Sprintf("unable to create public key file %q\n", err), 2)
+		return cli.NewExitError(fmt.Sprintf("unable to marshall public key %q\n", err), 2)
 	}
-
-	// generate and write to files
-	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	jwk, err := token.NewJWK(private.Public())
 	if err != nil {
-		return cli.NewExitError(fmt.Sprintf("error in generating private key %q\n", err), 2)
+		return cli.NewExitError(fmt.Sprintf("unable to convert public key to jwk %q\n", err), 2)
 	}
-	if err := private.Validate(); err != nil {
-		return cli.NewExitError(fmt.Sprintf("error in validating private key %q\n", err), 2)
+	files := []*keyFile{
+		{"private", 0600, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: prvCont})},
+		{"public", 0644, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubCont})},
 	}
-	prvPem := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(private),
+	if c.IsSet("jwk") {
+		b, err := json.MarshalIndent(jwk, "", "  ")
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("unable to encode jwk %q\n", err), 2)
+		}
+		files = append(files, &keyFile{"jwk", 0644, append(b, '\n')})
 	}
-	public := private.Public()
-	pubCont, err := x509.MarshalPKIXPublicKey(public)
-	if err != nil {
-		return cli.NewExitError(fmt.Sprintf("unable to marshall private key %q\n", err), 2)
+	if c.IsSet("jwks") {
+		b, err := json.MarshalIndent(&token.JWKS{Keys: []*token.JWK{jwk}}, "", "  ")
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("unable to encode jwks %q\n", err), 2)
+		}
+		files = append(files, &keyFile{"jwks", 0644, append(b, '\n')})
 	}
-	pubPem := &pem.Block{
-		Type:  "RSA PUBLIC KEY",
-		Bytes: pubCont,
+	// check all the files before writing any of them
+	if !c.Bool("force") {
+		for _, f := range files {
+			if _, err := os.Stat(c.String(f.flag)); err == nil {
+				return cli.NewExitError(
+					fmt.Sprintf("file %s exists, use --force to overwrite it", c.String(f.flag)),
+					2,
+				)
+			}
+		}
 	}
-	if err := pem.Encode(prvWriter, prvPem); err != nil {
-		return cli.NewExitError(fmt.Sprintf("unable to write private key %q\n", err), 2)
-	}
-	if err := pem.Encode(pubWriter, pubPem); err != nil {
-		return cli.NewExitError(fmt.Sprintf("unable to write public key %q\n", err), 2)
+	for _, f := range files {
+		if err := writeKeyFile(c.String(f.flag), f.perm, f.content); err != nil {
+			return cli.NewExitError(fmt.Sprintf("unable to write %s file %q\n", f.flag, err), 2)
+		}
 	}
+	fmt.Fprintf(c.App.Writer, "generated %s key with kid %s\n", jwk.Alg, jwk.Kid)
 	return nil
 }
+
+func generateKey(c *cli.Context) (crypto.Signer, error) {
+	switch c.String("type") {
+	case "ecdsa":
+		curve := elliptic.P256()
+		if c.String("curve") == "P-384" {
+			curve = elliptic.P384()
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case "ed25519":
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		private, err := rsa.GenerateKey(rand.Reader, c.Int("bits"))
+		if err != nil {
+			return nil, err
+		}
+		return private, private.Validate()
+	}
+}
+
+// Writes the file with the given permission, an existing file is
+// truncated and its permission is reset
+func writeKeyFile(name string, perm os.FileMode, content []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dictyBase/authserver/token"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// MintToken prints a signed token for the given user id, it is meant for
+// development and service accounts where a provider login is not possible
+func MintToken(c *cli.Context) error {
+	private, err := ioutil.ReadFile(c.String("private-key"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to read private key %q", err), 2)
+	}
+	pkey, err := token.ParsePrivateKey(private)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to parse private key %q", err), 2)
+	}
+	claims := token.NewClaims(c.Int64("user-id"), c.String("audience"), c.Duration("ttl"))
+	claims.Email = c.String("email")
+	claims.Roles = c.StringSlice("role")
+	tkn, err := token.Sign(pkey, claims)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in signing token %q", err), 2)
+	}
+	fmt.Fprintln(c.App.Writer, tkn)
+	return nil
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/dictyBase/authserver/config"
+	"github.com/dictyBase/authserver/handlers"
+	"github.com/dictyBase/authserver/health"
+	"github.com/dictyBase/authserver/message"
+	"github.com/dictyBase/authserver/token"
+	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
+)
+
+// Builds the checks of the readiness probe
+func readinessChecker(reqm message.Request, jt *handlers.Jwt, conf *config.Config, status *health.Status, timeout time.Duration) *health.Checker {
+	checker := health.NewChecker(status, timeout)
+	checker.Add("messaging", func(ctx context.Context) error {
+		if !reqm.IsActive() {
+			return errors.New("messaging server is disconnected")
+		}
+		return nil
+	})
+	// any reply from the services is good enough, even if
+	// the user or identity does not exist
+	checker.Add("user-service", func(ctx context.Context) error {
+		_, err := reqm.UserRequestWithContext(
+			ctx,
+			jt.Topics[message.UserExists],
+			&pubsub.IdRequest{Id: 0},
+		)
+		if err != nil {
+			return fmt.Errorf("no reply from user service %s", err)
+		}
+		return nil
+	})
+	checker.Add("identity-service", func(ctx context.Context) error {
+		_, err := reqm.IdentityRequestWithContext(
+			ctx,
+			jt.Topics[message.IdentityExists],
+			&pubsub.IdentityReq{Provider: "healthcheck", Identifier: "healthcheck"},
+		)
+		if err != nil {
+			return fmt.Errorf("no reply from identity service %s", err)
+		}
+		return nil
+	})
+	// the tokens are issued without roles when the permission
+	// service is down, so it is not needed for readiness
+	if !jt.RolesFallback {
+		checker.Add("permission-service", func(ctx context.Context) error {
+			_, err := reqm.RoleRequestWithContext(
+				ctx,
+				jt.Topics[message.RoleList],
+				&pubsub.IdRequest{Id: 0},
+			)
+			if err != nil {
+				return fmt.Errorf("no reply from permission service %s", err)
+			}
+			return nil
+		})
+	}
+	checker.Add("signing-keys", func(ctx context.Context) error {
+		return checkSigningKeys(jt)
+	})
+	checker.Add("providers", func(ctx context.Context) error {
+		if len(conf.Configured()) == 0 {
+			return errors.New("no provider secret is configured")
+		}
+		return nil
+	})
+	return checker
+}
+
+// Signs a token and verifies it with the public key to make sure the
+// keys are loaded and belong to the same pair
+func checkSigningKeys(jt *handlers.Jwt) error {
+	if jt.SignKey == nil || jt.VerifyKey == nil {
+		return errors.New("signing keys are not loaded")
+	}
+	signed, err := token.Sign(jt.SignKey, jwt.StandardClaims{Subject: "healthcheck"})
+	if err != nil {
+		return fmt.Errorf("unable to sign with private key %s", err)
+	}
+	_, err = jwt.Parse(signed, func(t *jwt.Token) (interface{}, error) {
+		return jt.VerifyKey, nil
+	})
+	if err != nil {
+		return fmt.Errorf("public key does not verify private key signature %s",
+			strings.TrimSpace(err.Error()))
+	}
+	return nil
+}
@@ -1,7 +1,8 @@
 package commands
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,126 +11,356 @@ import (
 	"os"
 	"time"
 
-	loggerMw "github.com/dictyBase/go-middlewares/middlewares/logrus"
 	"gopkg.in/urfave/cli.v1"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/certs"
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/config"
+	"github.com/dictyBase/authserver/device"
 	"github.com/dictyBase/authserver/handlers"
+	"github.com/dictyBase/authserver/health"
+	"github.com/dictyBase/authserver/message"
 	"github.com/dictyBase/authserver/message/nats"
+	"github.com/dictyBase/authserver/metrics"
 	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/oidc"
+	"github.com/dictyBase/authserver/ratelimit"
+	"github.com/dictyBase/authserver/resource"
+	"github.com/dictyBase/authserver/token"
+	"github.com/dictyBase/authserver/tracing"
+	"github.com/dictyBase/authserver/validate"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/jwtauth"
-	gnats "github.com/nats-io/go-nats"
+	gnats "github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
 )
 
 // Runs the http server
 func RunServer(c *cli.Context) error {
-	reqm, err := nats.NewRequest(
-		c.String("messaging-host"),
-		c.String("messaging-port"),
-		gnats.MaxReconnects(-1),
-		gnats.ReconnectWait(2*time.Second),
-	)
+	shutdownTracing, err := tracing.Init(&tracing.Options{
+		Exporter: c.String("tracing-exporter"),
+		Endpoint: c.String("tracing-endpoint"),
+		Insecure: c.Bool("tracing-insecure"),
+	})
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to setup tracing %s", err), 2)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("error in shutting down tracing %s\n", err)
+		}
+	}()
+	opts, err := messagingOptions(c)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("invalid messaging server options %s", err.Error()),
+			2,
+		)
+	}
+	reqm, err := nats.NewRequest(messagingURLs(c), opts...)
 	if err != nil {
 		return cli.NewExitError(
 			fmt.Sprintf("cannot connect to messaging server %s", err.Error()),
 			2,
 		)
 	}
-	config, err := readSecretConfig(c)
+	conf, err := config.Read(c.String("config"))
 	if err != nil {
-		return cli.NewExitError(fmt.Sprintf("Unable to secret config file %q\n", err), 2)
+		return cli.NewExitError(fmt.Sprintf("Unable to read config file %q\n", err), 2)
+	}
+	// the issuer of the id tokens should not come from the request headers
+	if conf.OIDC != nil && len(c.String("issuer-url")) == 0 {
+		return cli.NewExitError("argument issuer-url is required with the oidc section of config file", 2)
 	}
 	jt, err := parseJwtKeys(c)
 	if err != nil {
 		return cli.NewExitError(fmt.Sprintf("Unable to parse keys %q\n", err), 2)
 	}
-	// sets the reply messaging connection
-	jt.Request = reqm
-	jt.Topics = map[string]string{
-		"userExists":     "UserService.Exist",
-		"userGet":        "UserService.Get",
-		"identityExists": "IdentityService.Exist",
-		"identityGet":    "IdentityService.GetIdentity",
+	auditor, err := getAuditor(c, reqm)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to setup audit log %s", err), 2)
+	}
+	defer auditor.Close()
+	db, err := openStore(c)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to open store file %s", err), 2)
+	}
+	if db != nil {
+		defer db.Close()
+	}
+	patStore, err := getPATStore(db)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to setup personal access token store %s", err), 2)
+	}
+	sessions, err := getSessionStore(db)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to setup session store %s", err), 2)
 	}
-	loggerMw, err := getLoggerMiddleware(c)
+	jt.Auditor = auditor
+	jt.Sessions = sessions
+	// the memory store forgets the sessions of the tokens on restart
+	jt.AcceptUnknownSessions = db == nil
+	// sets the reply messaging connection
+	jt.Request = metrics.InstrumentRequest(reqm)
+	jt.Topics = conf.Topics()
+	jt.MaxPermissionsSize = conf.PermissionsSize()
+	jt.RolesFallback = conf.PermissionsFallback()
+	jt.Resources = conf.ResourceRegistry()
+	logger, err := getLoggerMiddleware(c)
 	if err != nil {
 		return cli.NewExitError(fmt.Sprintf("unable to get logger middlware %s", err), 2)
 	}
-	cors := cors.New(cors.Options{
-		AllowedOrigins:     []string{"*"},
-		AllowCredentials:   true,
-		OptionsPassthrough: true,
-	})
-
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(loggerMw.Middleware)
+	r.Use(metrics.Middleware)
+	r.Use(tracing.Middleware)
+	r.Use(logger.LoggerMiddleware)
 	r.Use(middleware.Recoverer)
-	r.Use(cors.Handler)
-	// Default health check
+	r.NotFound(apierror.NotFound)
+	r.MethodNotAllowed(apierror.MethodNotAllowed)
+	// Health checks, /healthz is kept for existing deployments
+	status := health.NewStatus()
+	checker := readinessChecker(reqm, jt, conf, status, c.Duration("readiness-timeout"))
+	r.Get("/livez", health.LiveHandler)
+	r.Get("/readyz", checker.ReadyHandler)
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if status.IsDraining() {
+			apierror.JSONAPIError(w, r, apierror.ErrUnavailable.New("server is shutting down"))
+			return
+		}
 		if !reqm.IsActive() {
-			http.Error(w, "messaging server is disconnected", http.StatusInternalServerError)
+			apierror.JSONAPIError(w, r, apierror.ErrUnavailable.New("messaging server is disconnected"))
 			return
 		}
 		w.Write([]byte("okay"))
 	})
-	googleMw := middlewares.GetGoogleMiddleware(config)
-	fbookMw := middlewares.GetFacebookMiddleware(config)
-	linkedInMw := middlewares.GetLinkedinMiddleware(config)
-	OrcidMw := middlewares.GetOrcidMiddleware(config)
+	googleMw := middlewares.GetGoogleMiddleware(&conf.ProvidersSecret)
+	fbookMw := middlewares.GetFacebookMiddleware(&conf.ProvidersSecret)
+	linkedInMw := middlewares.GetLinkedinMiddleware(&conf.ProvidersSecret)
+	OrcidMw := middlewares.GetOrcidMiddleware(&conf.ProvidersSecret)
+	limitStore := ratelimit.NewMemoryStore()
+	var tokenLimits, authorizeLimits *config.Limits
+	if conf.RateLimit != nil {
+		tokenLimits = conf.RateLimit.Tokens
+		authorizeLimits = conf.RateLimit.Authorize
+	}
+	clients := conf.Registry()
+	// the audience of the client assertions should not come from the
+	// request headers either
+	if clients.UsesAssertions() && len(c.String("issuer-url")) == 0 {
+		return cli.NewExitError("argument issuer-url is required with clients that have a jwks", 2)
+	}
+	userAuth := middlewares.UserAuthenticator(userAudiences(clients, jt.Resources))
 	r.Route("/tokens", func(r chi.Router) {
+		r.Use(cors.New(conf.TokensPolicy().Options()).Handler)
+		limiter := ratelimit.NewLimiter("tokens", limitStore, tokenLimits.Options())
+		r.Use(limiter.Middleware)
+		r.Use(limiter.CountFailures)
 		r.With(googleMw.ParamsMiddleware).
+			With(clients.Middleware("google")).
 			With(googleMw.GoogleMiddleware).Post("/google", jt.JwtHandler)
 		r.With(fbookMw.ParamsMiddleware).
+			With(clients.Middleware("facebook")).
 			With(fbookMw.FacebookMiddleware).Post("/facebook", jt.JwtHandler)
 		r.With(linkedInMw.ParamsMiddleware).
+			With(clients.Middleware("linkedin")).
 			With(linkedInMw.LinkedInMiddleware).Post("/linkedin", jt.JwtHandler)
 		r.With(OrcidMw.ParamsMiddleware).
+			With(clients.Middleware("orcid")).
 			With(OrcidMw.OrcidMiddleware).Post("/orcid", jt.JwtHandler)
 	})
+	oauth := &handlers.OAuth{
+		SignKey:        jt.SignKey,
+		VerifyKey:      jt.VerifyKey,
+		Clients:        clients,
+		Auditor:        auditor,
+		PersonalTokens: patStore,
+		Users:          jt,
+		IssuerURL:      c.String("issuer-url"),
+		Resources:      jt.Resources,
+	}
+	oauth.RegisterGrant("client_credentials", oauth.ClientCredentialsGrant)
+	oauth.RegisterGrant(handlers.TokenExchangeGrantType, oauth.TokenExchangeGrant)
+	alg, err := token.Algorithm(jt.SignKey)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unsupported signing key %s", err), 2)
+	}
+	tokenAuth := jwtauth.New(alg, jt.SignKey, jt.VerifyKey)
+	if conf.Device != nil {
+		oauth.Devices = device.NewStore(conf.Device.Lifetimes())
+		oauth.VerificationURI = conf.Device.VerificationURI
+		oauth.RegisterGrant(handlers.DeviceCodeGrantType, oauth.DeviceCodeGrant)
+	}
+	if conf.OIDC != nil {
+		oauth.Authorizations = oidc.NewStore(conf.OIDC.Lifetimes())
+		oauth.RefreshTokens = oidc.NewRefreshStore()
+		oauth.LoginURI = conf.OIDC.LoginURI
+		oauth.IDTokenTTL = conf.OIDC.IDTokenLifetime()
+		oauth.RegisterGrant("authorization_code", oauth.AuthorizationCodeGrant)
+		oauth.RegisterGrant("refresh_token", oauth.RefreshTokenGrant)
+	}
+	r.Get("/.well-known/jwks.json", oauth.JWKSHandler)
+	r.Route("/oauth", func(r chi.Router) {
+		limiter := ratelimit.NewLimiter("oauth", limitStore, tokenLimits.Options())
+		r.Use(limiter.Middleware)
+		r.With(limiter.CountFailures).Post("/token", oauth.TokenHandler)
+		if c.IsSet("tls-client-ca") {
+			r.With(middlewares.RequireClientCert).Post("/introspect", oauth.IntrospectHandler)
+		} else {
+			r.Post("/introspect", oauth.IntrospectHandler)
+		}
+		if conf.Device != nil {
+			r.Post("/device/code", oauth.DeviceCodeHandler)
+			r.Route("/device/verify", func(r chi.Router) {
+				r.Use(cors.New(conf.UsersPolicy().Options()).Handler)
+				r.Use(jwtauth.Verifier(tokenAuth))
+				r.Use(userAuth)
+				r.Use(jt.SessionMiddleware)
+				r.Use(middlewares.DenyImpersonation)
+				r.Get("/", oauth.DeviceInfoHandler)
+				r.Post("/", oauth.DeviceDecisionHandler)
+			})
+		}
+		if conf.OIDC != nil {
+			r.Get("/authorize", oauth.AuthorizeHandler)
+			r.Route("/authorize/consent", func(r chi.Router) {
+				r.Use(cors.New(conf.UsersPolicy().Options()).Handler)
+				r.Use(jwtauth.Verifier(tokenAuth))
+				r.Use(userAuth)
+				r.Use(jt.SessionMiddleware)
+				r.Use(middlewares.DenyImpersonation)
+				r.Get("/", oauth.ConsentInfoHandler)
+				r.Post("/", oauth.ConsentDecisionHandler)
+			})
+		}
+	})
+	if conf.OIDC != nil {
+		r.Get("/.well-known/openid-configuration", oauth.DiscoveryHandler)
+		r.Route("/userinfo", func(r chi.Router) {
+			r.Use(cors.New(conf.UsersPolicy().Options()).Handler)
+			r.Use(jwtauth.Verifier(tokenAuth))
+			r.Use(userAuth)
+			r.Use(jt.SessionMiddleware)
+			r.Get("/", oauth.UserInfoHandler)
+			r.Post("/", oauth.UserInfoHandler)
+		})
+	}
+	patDefault, patMax := conf.PATLifetimes()
+	pats := &handlers.PersonalTokens{
+		Store:      patStore,
+		Auditor:    auditor,
+		Scopes:     conf.PATScopes(),
+		DefaultTTL: patDefault,
+		MaxTTL:     patMax,
+		Resources:  jt.Resources,
+	}
+	r.Route("/users/me", func(r chi.Router) {
+		r.Use(cors.New(conf.UsersPolicy().Options()).Handler)
+		r.Use(jwtauth.Verifier(tokenAuth))
+		r.Use(userAuth)
+		r.Use(jt.SessionMiddleware)
+		r.Get("/", jt.CurrentUserHandler)
+		r.Get("/tokens", pats.ListHandler)
+		r.With(middlewares.DenyImpersonation).Post("/tokens", pats.CreateHandler)
+		r.With(middlewares.DenyImpersonation).Delete("/tokens/{id}", pats.RevokeHandler)
+		r.Get("/sessions", jt.ListSessionsHandler)
+		r.With(middlewares.DenyImpersonation).Delete("/sessions/{id}", jt.EndSessionHandler)
+	})
+	if conf.Impersonation != nil {
+		// every impersonation has to be in the audit trail
+		if !auditor.Enabled() {
+			return cli.NewExitError("impersonation needs an audit sink, give audit-file, audit-stdout or audit-subject", 2)
+		}
+		imp := &handlers.Impersonation{
+			Users:     jt,
+			Auditor:   auditor,
+			AdminRole: conf.Impersonation.AdminRole,
+			TokenTTL:  conf.Impersonation.Lifetime(),
+		}
+		r.Route("/users/{id}/impersonation", func(r chi.Router) {
+			r.Use(cors.New(conf.UsersPolicy().Options()).Handler)
+			r.Use(jwtauth.Verifier(tokenAuth))
+			r.Use(userAuth)
+			r.Use(jt.SessionMiddleware)
+			r.Use(middlewares.DenyImpersonation)
+			r.Post("/", imp.ImpersonateHandler)
+		})
+	}
 	r.Route("/authorize", func(r chi.Router) {
-		tokenAuth := jwtauth.New("RS512", jt.SignKey, jt.VerifyKey)
-		r.With(middlewares.AuthorizeMiddleware).
+		if c.IsSet("tls-client-ca") {
+			r.Use(middlewares.RequireClientCert)
+		}
+		if p := conf.AuthorizePolicy(); p != nil {
+			r.Use(cors.New(p.Options()).Handler)
+		}
+		r.Use(ratelimit.NewLimiter("authorize", limitStore, authorizeLimits.Options()).Middleware)
+		authorizer := &middlewares.Authorizer{Auditor: auditor, Resources: jt.Resources}
+		r.With(authorizer.AuthorizeMiddleware).
+			With(pats.AuthorizeMiddleware).
 			With(jwtauth.Verifier(tokenAuth)).
 			Post("/", jt.JwtFinalHandler)
 	})
 	if err := chi.Walk(r, walkFunc); err != nil {
 		log.Printf("error in printing routes %s\n", err)
 	}
-	log.Printf("Starting web server on port %d\n", c.Int("port"))
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", c.Int("port")), r))
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", c.Int("port")),
+		Handler:      r,
+		ReadTimeout:  c.Duration("read-timeout"),
+		WriteTimeout: c.Duration("write-timeout"),
+		IdleTimeout:  c.Duration("idle-timeout"),
+	}
+	// stops the background watchers once the server is shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if c.IsSet("tls-cert") {
+		tlsConf, err := tlsConfig(c, ctx.Done())
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("unable to setup tls %s", err), 2)
+		}
+		srv.TLSConfig = tlsConf
+	}
+	metricsSrv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", c.Int("metrics-port")),
+		Handler:      metricsRouter(),
+		ReadTimeout:  c.Duration("read-timeout"),
+		WriteTimeout: c.Duration("write-timeout"),
+	}
+	if err := serve(c, srv, metricsSrv, reqm, status); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
 	return nil
 }
 
+// Returns the router for the metrics server
+func metricsRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Method("GET", "/metrics", metrics.Handler())
+	return r
+}
+
 // Prints all the registered routes
 func walkFunc(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
 	log.Printf("method: %s - - route: %s\n", method, route)
 	return nil
 }
 
-// Reads the configuration file containing the various client secret keys
-// of the providers. The expected format will be ...
-//  {
-//		"google": "xxxxxxxxxxxx",
-//		"github": "xxxxxxxx",
-//	}
-func readSecretConfig(c *cli.Context) (*middlewares.ProvidersSecret, error) {
-	var provider *middlewares.ProvidersSecret
-	reader, err := os.Open(c.String("config"))
-	defer reader.Close()
-	if err != nil {
-		return provider, err
-	}
-	if err := json.NewDecoder(reader).Decode(&provider); err != nil {
-		return provider, err
-	}
-	return provider, nil
-
+// Returns the audiences of the tokens that could be used for the user
+// endpoints, the tokens of the resources are left out
+func userAudiences(clients *client.Registry, resources *resource.Registry) []string {
+	var audiences []string
+	for _, aud := range clients.Audiences() {
+		if _, ok := resources.ByAudience(aud); !ok {
+			audiences = append(audiences, aud)
+		}
+	}
+	return audiences
 }
 
 // Reads the public and private keys from their respective files and
@@ -140,7 +371,7 @@ func parseJwtKeys(c *cli.Context) (*handlers.Jwt, error) {
 	if err != nil {
 		return jh, err
 	}
-	pkey, err := jwt.ParseRSAPrivateKeyFromPEM(private)
+	pkey, err := token.ParsePrivateKey(private)
 	if err != nil {
 		return jh, err
 	}
@@ -149,33 +380,139 @@ func parseJwtKeys(c *cli.Context) (*handlers.Jwt, error) {
 	if err != nil {
 		return jh, err
 	}
-	pubkey, err := jwt.ParseRSAPublicKeyFromPEM(public)
+	pubkey, err := token.ParsePublicKey(public)
 	if err != nil {
 		return jh, err
 	}
+	palg, err := token.Algorithm(pkey)
+	if err != nil {
+		return jh, fmt.Errorf("unsupported private key %s", err)
+	}
+	alg, err := token.Algorithm(pubkey)
+	if err != nil {
+		return jh, fmt.Errorf("unsupported public key %s", err)
+	}
+	if alg != palg {
+		return jh, fmt.Errorf("private key is for %s but public key is for %s", palg, alg)
+	}
 	jh.VerifyKey = pubkey
 	jh.SignKey = pkey
 	return jh, err
 }
 
-// GetLoggerMiddleware gets a net/http compatible instance of logrus
-func getLoggerMiddleware(c *cli.Context) (*loggerMw.Logger, error) {
-	var logger *loggerMw.Logger
+// Returns the list of messaging server urls, either from the url flag or
+// from the host and port flags
+func messagingURLs(c *cli.Context) []string {
+	if urls := c.StringSlice("messaging-url"); len(urls) > 0 {
+		return urls
+	}
+	return []string{
+		fmt.Sprintf("nats://%s:%s", c.String("messaging-host"), c.String("messaging-port")),
+	}
+}
+
+// Builds the connection options for the messaging server from the tls and
+// authentication flags
+func messagingOptions(c *cli.Context) ([]gnats.Option, error) {
+	opts, err := validate.MessagingSecurity(c).Options()
+	if err != nil {
+		return opts, err
+	}
+	return append(
+		opts,
+		gnats.Name("authserver"),
+		gnats.MaxReconnects(-1),
+		gnats.ReconnectWait(2*time.Second),
+	), nil
+}
+
+// Builds the tls configuration of the web server from the certificate
+// and key files, which are watched for changes. If a client certificate
+// authority is given, client certificates are verified against it. The
+// files are watched until the stop channel is closed.
+func tlsConfig(c *cli.Context, stop <-chan struct{}) (*tls.Config, error) {
+	reloader, err := certs.NewReloader(c.String("tls-cert"), c.String("tls-key"))
+	if err != nil {
+		return nil, err
+	}
+	go reloader.Watch(c.Duration("tls-reload-interval"), stop)
+	conf := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if c.IsSet("tls-client-ca") {
+		pool, err := certs.ReadCertPool(c.String("tls-client-ca"))
+		if err != nil {
+			return nil, err
+		}
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return conf, nil
+}
+
+// Builds the auditor from the sinks given in the audit flags
+func getAuditor(c *cli.Context, reqm message.Request) (*audit.Auditor, error) {
+	var sinks []audit.Sink
+	if c.IsSet("audit-file") {
+		sinks = append(sinks, audit.NewFileSink(c.String("audit-file"), &audit.FileOptions{
+			MaxSize:    c.Int("audit-max-size"),
+			MaxBackups: c.Int("audit-max-backups"),
+			MaxAge:     c.Int("audit-max-age"),
+		}))
+	}
+	if c.Bool("audit-stdout") {
+		sinks = append(sinks, audit.NewStdoutSink())
+	}
+	if c.IsSet("audit-subject") {
+		pub, err := nats.NewPublisher(reqm)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, audit.NewPublishSink(pub, c.String("audit-subject")))
+	}
+	return audit.NewAuditor(sinks...), nil
+}
+
+// Query parameters that are redacted from the request log by default
+var defaultRedactedParams = []string{
+	"code",
+	"state",
+	"token",
+	"access_token",
+	"id_token",
+	"refresh_token",
+	"client_secret",
+}
+
+// Builds the request logger from the global log flags
+func getLoggerMiddleware(c *cli.Context) (*middlewares.Logger, error) {
 	var w io.Writer
-	if c.IsSet("log-file") {
-		fw, err := os.Create(c.String("log-file"))
+	if c.GlobalIsSet("log-file") {
+		fw, err := os.Create(c.GlobalString("log-file"))
 		if err != nil {
-			return logger,
-				fmt.Errorf("could not open log file  %s %s", c.String("log-file"), err)
+			return nil,
+				fmt.Errorf("could not open log file  %s %s", c.GlobalString("log-file"), err)
 		}
 		w = io.MultiWriter(fw, os.Stderr)
 	} else {
 		w = os.Stderr
 	}
-	if c.String("log-format") == "json" {
-		logger = loggerMw.NewJSONFileLogger(w)
-	} else {
-		logger = loggerMw.NewFileLogger(w)
+	level, err := logrus.ParseLevel(c.GlobalString("log-level"))
+	if err != nil {
+		return nil, err
+	}
+	var formatter logrus.Formatter = &logrus.TextFormatter{FullTimestamp: true}
+	if c.GlobalString("log-format") == "json" {
+		formatter = &logrus.JSONFormatter{}
+	}
+	logger := middlewares.NewCustomMiddleware(level, formatter, "authserver")
+	logger.Logrus.Out = w
+	redacted := c.GlobalStringSlice("log-redact")
+	if len(redacted) == 0 {
+		redacted = defaultRedactedParams
 	}
+	logger.SetRedactedParams(redacted...)
+	logger.SetSampling("/authorize", c.GlobalFloat64("log-authorize-sample"))
 	return logger, nil
 }
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dictyBase/authserver/health"
+	"github.com/dictyBase/authserver/message"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Runs the http and metrics servers until it receives a SIGINT or SIGTERM
+// and then shuts them down gracefully. The readiness is failed first, then
+// the server stops accepting new connections and waits for the in-flight
+// requests to finish up to the grace period. At the end the messaging
+// connection is drained within what is left of the grace period.
+func serve(c *cli.Context, srv, metricsSrv *http.Server, reqm message.Request, status *health.Status) error {
+	errCh := make(chan error, 2)
+	go func() {
+		log.Printf("Starting metrics server on port %d\n", c.Int("metrics-port"))
+		if err := metricsSrv.ListenAndServe(); err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			log.Printf("Starting web server with tls on port %d\n", c.Int("port"))
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting web server on port %d\n", c.Int("port"))
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("error in running web server %s", err)
+	case sig := <-sigCh:
+		log.Printf("received signal %s, shutting down the web server\n", sig)
+	}
+
+	status.Drain()
+	if d := c.Duration("shutdown-delay"); d > 0 {
+		log.Printf("waiting %s before closing the listener\n", d)
+		time.Sleep(d)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("shutdown-timeout"))
+	defer cancel()
+	var serr error
+	if err := srv.Shutdown(ctx); err != nil {
+		serr = fmt.Errorf("error in shutting down web server %s", err)
+		log.Println(serr)
+	}
+	if err := metricsSrv.Shutdown(ctx); err != nil {
+		log.Printf("error in shutting down metrics server %s\n", err)
+	}
+	if err := reqm.Drain(ctx); err != nil {
+		log.Printf("error in draining messaging connection %s\n", err)
+	}
+	log.Println("web server is shut down")
+	return serr
+}
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"log"
+	"time"
+
+	"github.com/dictyBase/authserver/pat"
+	"github.com/dictyBase/authserver/session"
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Opens the embedded database given by the store-file flag, it is nil
+// if the flag is not given and the stores are kept in memory
+func openStore(c *cli.Context) (*bolt.DB, error) {
+	if !c.IsSet("store-file") {
+		log.Println("store-file is not given, personal access tokens and sessions are kept in memory and lost on restart")
+		return nil, nil
+	}
+	return bolt.Open(c.String("store-file"), 0600, &bolt.Options{Timeout: 5 * time.Second})
+}
+
+// Returns the store of the personal access tokens, in the database
+// if it is opened
+func getPATStore(db *bolt.DB) (pat.Store, error) {
+	if db == nil {
+		return pat.NewMemoryStore(), nil
+	}
+	return pat.NewBoltStore(db)
+}
+
+// Returns the store of the login sessions, in the database if it is
+// opened
+func getSessionStore(db *bolt.DB) (session.Store, error) {
+	if db == nil {
+		return session.NewMemoryStore(), nil
+	}
+	return session.NewBoltStore(db)
+}
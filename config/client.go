@@ -0,0 +1,102 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/token"
+)
+
+// Client is an entry of the client registry, any setting that is
+// not given has the value of the tokens issued to unregistered clients
+type Client struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Audience         string   `json:"audience"`
+	AccessTTL        Duration `json:"access_ttl"`
+	RefreshTTL       Duration `json:"refresh_ttl"`
+	AllowedProviders []string `json:"allowed_providers"`
+	RedirectURLs     []string `json:"redirect_urls"`
+	RequiredRoles    []string `json:"required_roles"`
+	// Hex encoded sha256 hash of the secret of a confidential client
+	SecretHash string `json:"secret_hash"`
+	// Public keys of a confidential client that authenticates
+	// with private_key_jwt
+	JWKS *token.JWKS `json:"jwks"`
+	// Scopes that could be granted to the client
+	Scopes []string `json:"scopes"`
+	// Audiences of the resources the client could exchange tokens for
+	ExchangeAudiences []string `json:"exchange_audiences"`
+}
+
+// Validate checks the entry for missing id and unusable settings
+func (c *Client) Validate() error {
+	if len(c.ID) == 0 {
+		return fmt.Errorf("client id is missing")
+	}
+	if c.AccessTTL < 0 || c.RefreshTTL < 0 {
+		return fmt.Errorf("client %s has negative token lifetime", c.ID)
+	}
+	for _, p := range c.AllowedProviders {
+		switch p {
+		case "google", "facebook", "linkedin", "orcid":
+		default:
+			return fmt.Errorf("client %s has unknown provider %s", c.ID, p)
+		}
+	}
+	if len(c.SecretHash) > 0 {
+		if b, err := hex.DecodeString(c.SecretHash); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("client %s should have a hex encoded sha256 secret_hash", c.ID)
+		}
+	}
+	if c.JWKS != nil {
+		for _, k := range c.JWKS.Keys {
+			if _, err := k.PublicKey(); err != nil {
+				return fmt.Errorf("client %s has invalid key %s", c.ID, err)
+			}
+		}
+	}
+	for _, r := range c.RedirectURLs {
+		u, err := url.Parse(r)
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return fmt.Errorf("client %s has invalid redirect url %s", c.ID, r)
+		}
+	}
+	return nil
+}
+
+// Client converts the entry for the registry, filling in the defaults
+func (c *Client) Client() *client.Client {
+	cl := client.Default(c.ID)
+	cl.Name = c.Name
+	cl.RefreshTTL = time.Duration(c.RefreshTTL)
+	cl.AllowedProviders = c.AllowedProviders
+	cl.RedirectURLs = c.RedirectURLs
+	cl.RequiredRoles = c.RequiredRoles
+	cl.SecretHash = strings.ToLower(c.SecretHash)
+	cl.JWKS = c.JWKS
+	cl.Scopes = c.Scopes
+	cl.ExchangeAudiences = c.ExchangeAudiences
+	if len(c.Audience) > 0 {
+		cl.Audience = c.Audience
+	}
+	if c.AccessTTL > 0 {
+		cl.AccessTTL = time.Duration(c.AccessTTL)
+	}
+	return cl
+}
+
+// Registry returns the registry of the configured clients, without any
+// client every client gets tokens with the default settings
+func (c *Config) Registry() *client.Registry {
+	var clients []*client.Client
+	for _, cl := range c.Clients {
+		clients = append(clients, cl.Client())
+	}
+	return client.NewRegistry(clients)
+}
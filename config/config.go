@@ -0,0 +1,164 @@
+// package config reads and validates the json formatted
+// configuration file of the server
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dictyBase/authserver/message"
+	"github.com/dictyBase/authserver/middlewares"
+)
+
+// Config is the configuration file of the server. The client secret keys
+// of the providers are kept at the top level, the rest of the
+// configuration goes into its own sections. The expected format will be ...
+//
+//	{
+//		"google": "xxxxxxxxxxxx",
+//		"github": "xxxxxxxx",
+//		"messaging": {
+//			"prefix": "staging",
+//			"topics": {
+//				"userGet": "UserService.Get"
+//			}
+//		}
+//	}
+type Config struct {
+	middlewares.ProvidersSecret
+	Messaging *Messaging `json:"messaging"`
+	RateLimit *RateLimit `json:"rate_limit"`
+	CORS      *CORS      `json:"cors"`
+	Clients   []*Client  `json:"clients"`
+	// Settings of the personal access tokens
+	PersonalTokens *PersonalTokens `json:"personal_tokens"`
+	// Settings of the device flow, it is disabled if not given
+	Device *Device `json:"device"`
+	// Settings of the openid connect provider, it is disabled if not given
+	OIDC *OIDC `json:"oidc"`
+	// Settings of the permissions in the tokens
+	Permissions *Permissions `json:"permissions"`
+	// Services behind the ingress and the tokens they accept
+	Resources []*Resource `json:"resources"`
+	// Settings of the impersonation by admins, it is disabled if not given
+	Impersonation *Impersonation `json:"impersonation"`
+}
+
+// Messaging configures the subjects of the messaging topics
+type Messaging struct {
+	// Prefix added to all the subjects, for example the name of the
+	// environment
+	Prefix string `json:"prefix"`
+	// Subjects that overrides the default ones
+	Topics map[string]string `json:"topics"`
+}
+
+// Read reads and validates the configuration file
+func Read(file string) (*Config, error) {
+	config := &Config{}
+	reader, err := os.Open(file)
+	if err != nil {
+		return config, err
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(config); err != nil {
+		return config, fmt.Errorf("unable to decode config file %s", err)
+	}
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Validate checks the configuration for errors that would
+// otherwise show up at request time
+func (c *Config) Validate() error {
+	if err := c.Topics().Validate(); err != nil {
+		return fmt.Errorf("error in messaging section %s", err)
+	}
+	if c.CORS != nil {
+		for name, p := range map[string]*CORSPolicy{
+			"tokens":    c.CORS.Tokens,
+			"authorize": c.CORS.Authorize,
+			"users":     c.CORS.Users,
+		} {
+			if p == nil {
+				continue
+			}
+			if err := p.Validate(); err != nil {
+				return fmt.Errorf("error in cors %s section %s", name, err)
+			}
+		}
+	}
+	if c.RateLimit != nil {
+		for name, l := range map[string]*Limits{
+			"tokens":    c.RateLimit.Tokens,
+			"authorize": c.RateLimit.Authorize,
+		} {
+			if l == nil {
+				continue
+			}
+			if err := l.Validate(); err != nil {
+				return fmt.Errorf("error in rate_limit %s section %s", name, err)
+			}
+		}
+		if a := c.RateLimit.Authorize; a != nil && a.FailedLogins != nil {
+			return fmt.Errorf("error in rate_limit authorize section, failed_logins is only counted for the logins")
+		}
+	}
+	if p := c.PersonalTokens; p != nil {
+		if p.DefaultTTL < 0 || p.MaxTTL < 0 {
+			return fmt.Errorf("error in personal_tokens section, lifetime should not be negative")
+		}
+		if p.DefaultTTL > 0 && p.MaxTTL > 0 && p.DefaultTTL > p.MaxTTL {
+			return fmt.Errorf("error in personal_tokens section, default_ttl is more than max_ttl")
+		}
+	}
+	if c.Device != nil {
+		if err := c.Device.Validate(); err != nil {
+			return fmt.Errorf("error in device section %s", err)
+		}
+	}
+	if c.OIDC != nil {
+		if err := c.OIDC.Validate(); err != nil {
+			return fmt.Errorf("error in oidc section %s", err)
+		}
+	}
+	if c.Impersonation != nil {
+		if err := c.Impersonation.Validate(); err != nil {
+			return fmt.Errorf("error in impersonation section %s", err)
+		}
+	}
+	ids := make(map[string]bool)
+	for _, cl := range c.Clients {
+		if err := cl.Validate(); err != nil {
+			return fmt.Errorf("error in clients section %s", err)
+		}
+		if ids[cl.ID] {
+			return fmt.Errorf("error in clients section, client %s is given more than once", cl.ID)
+		}
+		ids[cl.ID] = true
+	}
+	audiences := make(map[string]bool)
+	for _, r := range c.Resources {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("error in resources section %s", err)
+		}
+		if audiences[r.Audience] {
+			return fmt.Errorf("error in resources section, audience %s is given more than once", r.Audience)
+		}
+		audiences[r.Audience] = true
+	}
+	return nil
+}
+
+// Topics returns the subjects of the messaging topics, the
+// defaults are used for anything that is not configured
+func (c *Config) Topics() message.Topics {
+	topics := message.DefaultTopics()
+	if c.Messaging == nil {
+		return topics
+	}
+	return topics.Merge(c.Messaging.Topics).WithPrefix(c.Messaging.Prefix)
+}
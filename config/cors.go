@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/cors"
+)
+
+// CORS configures the cross origin policies of the /tokens, /users and
+// /authorize routes. Without a policy for /tokens or /users any origin is
+// allowed without credentials, without a policy for /authorize no cross
+// origin request is allowed.
+type CORS struct {
+	Tokens    *CORSPolicy `json:"tokens"`
+	Users     *CORSPolicy `json:"users"`
+	Authorize *CORSPolicy `json:"authorize"`
+}
+
+// CORSPolicy is the cross origin policy of a group of routes. An origin
+// could have a single wildcard for matching the subdomains, for
+// example https://*.dictybase.org
+type CORSPolicy struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	// Seconds for caching the result of a preflight request
+	MaxAge int `json:"max_age"`
+}
+
+// DefaultTokensCORS is the policy of the /tokens routes if none is configured
+func DefaultTokensCORS() *CORSPolicy {
+	return &CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"POST"},
+		AllowedHeaders: []string{"Accept", "Content-Type"},
+		MaxAge:         300,
+	}
+}
+
+// DefaultUsersCORS is the policy of the /users routes if none is configured
+func DefaultUsersCORS() *CORSPolicy {
+	return &CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "DELETE"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type"},
+		MaxAge:         300,
+	}
+}
+
+// Validate checks the policy for origins that browsers would reject
+func (p *CORSPolicy) Validate() error {
+	if len(p.AllowedOrigins) == 0 {
+		return fmt.Errorf("allowed_origins is empty")
+	}
+	for _, o := range p.AllowedOrigins {
+		if o == "*" {
+			if p.AllowCredentials {
+				return fmt.Errorf("allowed origin * could not be used with allow_credentials")
+			}
+			continue
+		}
+		if strings.Count(o, "*") > 1 {
+			return fmt.Errorf("origin %s has more than one wildcard", o)
+		}
+		u, err := url.Parse(strings.Replace(o, "*", "wildcard", 1))
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return fmt.Errorf("origin %s should be in scheme://host form", o)
+		}
+		if len(strings.Trim(u.Path, "/")) > 0 {
+			return fmt.Errorf("origin %s should not have a path", o)
+		}
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("max_age should not be negative")
+	}
+	return nil
+}
+
+// Options converts the policy for the cors middleware
+func (p *CORSPolicy) Options() cors.Options {
+	return cors.Options{
+		AllowedOrigins:   p.AllowedOrigins,
+		AllowedMethods:   p.AllowedMethods,
+		AllowedHeaders:   p.AllowedHeaders,
+		ExposedHeaders:   p.ExposedHeaders,
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           p.MaxAge,
+	}
+}
+
+// TokensPolicy returns the policy of the /tokens routes
+func (c *Config) TokensPolicy() *CORSPolicy {
+	if c.CORS == nil || c.CORS.Tokens == nil {
+		return DefaultTokensCORS()
+	}
+	return c.CORS.Tokens
+}
+
+// UsersPolicy returns the policy of the /users routes
+func (c *Config) UsersPolicy() *CORSPolicy {
+	if c.CORS == nil || c.CORS.Users == nil {
+		return DefaultUsersCORS()
+	}
+	return c.CORS.Users
+}
+
+// AuthorizePolicy returns the policy of the /authorize route, it
+// is nil if cross origin requests are not allowed
+func (c *Config) AuthorizePolicy() *CORSPolicy {
+	if c.CORS == nil {
+		return nil
+	}
+	return c.CORS.Authorize
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCORSPolicyValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy *CORSPolicy
+		err    string
+	}{
+		{"default tokens", DefaultTokensCORS(), ""},
+		{"default users", DefaultUsersCORS(), ""},
+		{"subdomain wildcard", &CORSPolicy{AllowedOrigins: []string{"https://*.dictybase.org"}, AllowCredentials: true}, ""},
+		{"empty origins", &CORSPolicy{}, "allowed_origins is empty"},
+		{"any origin with credentials", &CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "could not be used with allow_credentials"},
+		{"two wildcards", &CORSPolicy{AllowedOrigins: []string{"https://*.*.dictybase.org"}}, "more than one wildcard"},
+		{"no scheme", &CORSPolicy{AllowedOrigins: []string{"dictybase.org"}}, "scheme://host"},
+		{"path", &CORSPolicy{AllowedOrigins: []string{"https://dictybase.org/stocks"}}, "should not have a path"},
+		{"negative max age", &CORSPolicy{AllowedOrigins: []string{"https://dictybase.org"}, MaxAge: -1}, "max_age"},
+	}
+	for _, c := range cases {
+		err := c.policy.Validate()
+		switch {
+		case len(c.err) == 0 && err != nil:
+			t.Errorf("%s: unexpected error %s", c.name, err)
+		case len(c.err) > 0 && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: expected error with %q, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	c := &Config{}
+	if p := c.TokensPolicy(); p.AllowedOrigins[0] != "*" {
+		t.Errorf("expected default tokens policy, got %v", p.AllowedOrigins)
+	}
+	if p := c.AuthorizePolicy(); p != nil {
+		t.Errorf("expected no authorize policy, got %v", p)
+	}
+	users := &CORSPolicy{AllowedOrigins: []string{"https://dictybase.org"}}
+	c.CORS = &CORS{Users: users}
+	if p := c.UsersPolicy(); p != users {
+		t.Errorf("expected configured users policy, got %v", p)
+	}
+}
+
+func TestValidateUsersPolicy(t *testing.T) {
+	c := &Config{CORS: &CORS{
+		Users: &CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+	}}
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cors users section") {
+		t.Errorf("expected error in cors users section, got %v", err)
+	}
+}
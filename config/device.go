@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// default settings of the device flow
+const (
+	defaultDeviceTTL      = 10 * time.Minute
+	defaultDeviceInterval = 5 * time.Second
+)
+
+// Device configures the device authorization grant, the flow is
+// enabled only if it is configured
+type Device struct {
+	// Url of the page where the users enter the code
+	VerificationURI string `json:"verification_uri"`
+	// Lifetime of the device and user codes
+	TTL Duration `json:"ttl"`
+	// Minimum time between two polls of the device
+	Interval Duration `json:"interval"`
+}
+
+// Validate checks for the verification uri
+func (d *Device) Validate() error {
+	u, err := url.Parse(d.VerificationURI)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("verification_uri should be an absolute url")
+	}
+	if d.TTL < 0 || d.Interval < 0 {
+		return fmt.Errorf("ttl and interval should not be negative")
+	}
+	return nil
+}
+
+// Lifetimes returns the lifetime of the codes and the poll interval
+func (d *Device) Lifetimes() (time.Duration, time.Duration) {
+	ttl, interval := defaultDeviceTTL, defaultDeviceInterval
+	if d.TTL > 0 {
+		ttl = time.Duration(d.TTL)
+	}
+	if d.Interval > 0 {
+		interval = time.Duration(d.Interval)
+	}
+	return ttl, interval
+}
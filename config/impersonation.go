@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// default and longest lifetime of the impersonation tokens
+const (
+	defaultImpersonationTTL = 15 * time.Minute
+	maxImpersonationTTL     = time.Hour
+)
+
+// Impersonation configures the tokens that admins get to act as another
+// user, the endpoint is enabled only if it is configured
+type Impersonation struct {
+	// Role of the users who could impersonate, the users with the role
+	// could not be impersonated
+	AdminRole string `json:"admin_role"`
+	// Lifetime of the impersonation tokens
+	TokenTTL Duration `json:"token_ttl"`
+}
+
+// Validate checks for the admin role and the lifetime
+func (i *Impersonation) Validate() error {
+	if len(i.AdminRole) == 0 {
+		return fmt.Errorf("admin_role is required")
+	}
+	if i.TokenTTL < 0 || time.Duration(i.TokenTTL) > maxImpersonationTTL {
+		return fmt.Errorf("token_ttl should be within %s", maxImpersonationTTL)
+	}
+	return nil
+}
+
+// Lifetime returns the lifetime of the impersonation tokens
+func (i *Impersonation) Lifetime() time.Duration {
+	if i.TokenTTL > 0 {
+		return time.Duration(i.TokenTTL)
+	}
+	return defaultImpersonationTTL
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// default lifetimes of the openid connect authorization requests and codes
+const (
+	defaultRequestTTL = 10 * time.Minute
+	defaultCodeTTL    = time.Minute
+	defaultIDTokenTTL = time.Hour
+)
+
+// OIDC configures the openid connect provider, it is enabled only if it
+// is configured
+type OIDC struct {
+	// Url of the page where the users login and approve the requests
+	LoginURI string `json:"login_uri"`
+	// Time the users have for login and approval
+	RequestTTL Duration `json:"request_ttl"`
+	// Lifetime of the authorization codes
+	CodeTTL Duration `json:"code_ttl"`
+	// Lifetime of the id tokens
+	IDTokenTTL Duration `json:"id_token_ttl"`
+}
+
+// Validate checks for the login uri
+func (o *OIDC) Validate() error {
+	u, err := url.Parse(o.LoginURI)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("login_uri should be an absolute url")
+	}
+	if o.RequestTTL < 0 || o.CodeTTL < 0 || o.IDTokenTTL < 0 {
+		return fmt.Errorf("request_ttl, code_ttl and id_token_ttl should not be negative")
+	}
+	return nil
+}
+
+// Lifetimes returns the lifetimes of the requests and the codes
+func (o *OIDC) Lifetimes() (time.Duration, time.Duration) {
+	request, code := defaultRequestTTL, defaultCodeTTL
+	if o.RequestTTL > 0 {
+		request = time.Duration(o.RequestTTL)
+	}
+	if o.CodeTTL > 0 {
+		code = time.Duration(o.CodeTTL)
+	}
+	return request, code
+}
+
+// IDTokenLifetime returns the lifetime of the id tokens
+func (o *OIDC) IDTokenLifetime() time.Duration {
+	if o.IDTokenTTL > 0 {
+		return time.Duration(o.IDTokenTTL)
+	}
+	return defaultIDTokenTTL
+}
@@ -0,0 +1,45 @@
+package config
+
+import "time"
+
+// default lifetimes of the personal access tokens
+const (
+	defaultPATTTL = 30 * 24 * time.Hour
+	maxPATTTL     = 365 * 24 * time.Hour
+)
+
+// PersonalTokens configures the personal access tokens of the users
+type PersonalTokens struct {
+	// Scopes that could be given to a token
+	Scopes []string `json:"scopes"`
+	// Lifetime of a token if none is requested
+	DefaultTTL Duration `json:"default_ttl"`
+	// Longest lifetime of a token
+	MaxTTL Duration `json:"max_ttl"`
+}
+
+// PATScopes returns the scopes that could be given to a personal access token
+func (c *Config) PATScopes() []string {
+	if c.PersonalTokens == nil {
+		return nil
+	}
+	return c.PersonalTokens.Scopes
+}
+
+// PATLifetimes returns the default and the longest lifetimes of the
+// personal access tokens
+func (c *Config) PATLifetimes() (time.Duration, time.Duration) {
+	def, max := defaultPATTTL, maxPATTTL
+	if p := c.PersonalTokens; p != nil {
+		if p.DefaultTTL > 0 {
+			def = time.Duration(p.DefaultTTL)
+		}
+		if p.MaxTTL > 0 {
+			max = time.Duration(p.MaxTTL)
+		}
+	}
+	if def > max {
+		def = max
+	}
+	return def, max
+}
@@ -0,0 +1,33 @@
+package config
+
+// default limit of the size of the permissions in a token, in bytes
+const defaultPermissionsSize = 2048
+
+// Permissions configures how the permissions of the users are added to
+// their tokens
+type Permissions struct {
+	// Longest size of the permissions in bytes, beyond it only the
+	// version of the permissions is added. A negative size has no limit.
+	MaxSize int `json:"max_size"`
+	// Issue the tokens without roles and permissions when the
+	// permission service fails, instead of failing the login
+	Fallback bool `json:"fallback"`
+}
+
+// PermissionsSize returns the size limit of the permissions in a token,
+// zero means no limit
+func (c *Config) PermissionsSize() int {
+	if c.Permissions == nil || c.Permissions.MaxSize == 0 {
+		return defaultPermissionsSize
+	}
+	if c.Permissions.MaxSize < 0 {
+		return 0
+	}
+	return c.Permissions.MaxSize
+}
+
+// PermissionsFallback tells if the tokens are issued without roles and
+// permissions when the permission service fails
+func (c *Config) PermissionsFallback() bool {
+	return c.Permissions != nil && c.Permissions.Fallback
+}
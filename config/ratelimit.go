@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dictyBase/authserver/ratelimit"
+)
+
+// RateLimit configures the limits of the /tokens and /authorize routes
+type RateLimit struct {
+	Tokens    *Limits `json:"tokens"`
+	Authorize *Limits `json:"authorize"`
+}
+
+// Limits configures the limits of a group of routes, any limit
+// that is missing is not enforced
+type Limits struct {
+	PerIP        *Rule    `json:"per_ip"`
+	PerClient    *Rule    `json:"per_client"`
+	FailedLogins *Lockout `json:"failed_logins"`
+}
+
+// Rule allows a number of requests within a window
+type Rule struct {
+	Requests int64    `json:"requests"`
+	Window   Duration `json:"window"`
+}
+
+// Lockout blocks a client ip for the lockout duration after
+// the maximum number of failed logins within the window
+type Lockout struct {
+	MaxFailures int64    `json:"max_failures"`
+	Window      Duration `json:"window"`
+	Lockout     Duration `json:"lockout"`
+}
+
+// Validate checks that every configured limit is usable
+func (l *Limits) Validate() error {
+	for name, r := range map[string]*Rule{"per_ip": l.PerIP, "per_client": l.PerClient} {
+		if r == nil {
+			continue
+		}
+		if r.Requests <= 0 || r.Window <= 0 {
+			return fmt.Errorf("%s needs positive requests and window", name)
+		}
+	}
+	if lo := l.FailedLogins; lo != nil {
+		if lo.MaxFailures <= 0 || lo.Window <= 0 || lo.Lockout <= 0 {
+			return fmt.Errorf("failed_logins needs positive max_failures, window and lockout")
+		}
+	}
+	return nil
+}
+
+// Options converts the limits for the rate limiter, a nil
+// receiver gives no limit
+func (l *Limits) Options() *ratelimit.Options {
+	opts := &ratelimit.Options{}
+	if l == nil {
+		return opts
+	}
+	if l.PerIP != nil {
+		opts.PerIP = l.PerIP.rule()
+	}
+	if l.PerClient != nil {
+		opts.PerClient = l.PerClient.rule()
+	}
+	if lo := l.FailedLogins; lo != nil {
+		opts.FailedLogins = &ratelimit.Lockout{
+			MaxFailures: lo.MaxFailures,
+			Window:      time.Duration(lo.Window),
+			Lockout:     time.Duration(lo.Lockout),
+		}
+	}
+	return opts
+}
+
+func (r *Rule) rule() *ratelimit.Rule {
+	return &ratelimit.Rule{Requests: r.Requests, Window: time.Duration(r.Window)}
+}
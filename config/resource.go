@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/authserver/resource"
+)
+
+// Resource is an entry of the resource registry
+type Resource struct {
+	Audience       string   `json:"audience"`
+	Host           string   `json:"host"`
+	PathPrefix     string   `json:"path_prefix"`
+	Scopes         []string `json:"scopes"`
+	RequiredScopes []string `json:"required_scopes"`
+	TokenTTL       Duration `json:"token_ttl"`
+}
+
+// Validate checks for the audience and the path prefix
+func (r *Resource) Validate() error {
+	if len(r.Audience) == 0 {
+		return fmt.Errorf("resource audience is missing")
+	}
+	if !strings.HasPrefix(r.PathPrefix, "/") {
+		return fmt.Errorf("resource %s should have a path_prefix starting with /", r.Audience)
+	}
+	if r.TokenTTL < 0 {
+		return fmt.Errorf("resource %s has negative token lifetime", r.Audience)
+	}
+	return nil
+}
+
+// Resource converts the entry for the registry, filling in the defaults
+func (r *Resource) Resource() *resource.Resource {
+	res := &resource.Resource{
+		Audience:       r.Audience,
+		Host:           r.Host,
+		PathPrefix:     r.PathPrefix,
+		Scopes:         r.Scopes,
+		RequiredScopes: r.RequiredScopes,
+		TokenTTL:       resource.DefaultTTL,
+	}
+	if r.TokenTTL > 0 {
+		res.TokenTTL = time.Duration(r.TokenTTL)
+	}
+	return res
+}
+
+// ResourceRegistry returns the registry of the configured resources
+func (c *Config) ResourceRegistry() *resource.Registry {
+	var resources []*resource.Resource
+	for _, r := range c.Resources {
+		resources = append(resources, r.Resource())
+	}
+	return resource.NewRegistry(resources)
+}
@@ -0,0 +1,229 @@
+// package device keeps the pending authorizations of the oauth2 device
+// authorization grant(RFC 8628). They are short lived and are kept in
+// memory.
+package device
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// characters of the user code, without vowels and look alike letters
+const userCodeChars = "BCDFGHJKLMNPQRSTVWXZ"
+
+var (
+	// ErrNotFound is returned for an unknown or already used code
+	ErrNotFound = errors.New("device authorization is not found")
+	// ErrExpired is returned for an authorization past its expiry
+	ErrExpired = errors.New("device authorization is expired")
+	// ErrPending is returned while the user has not decided
+	ErrPending = errors.New("authorization is pending")
+	// ErrSlowDown is returned when the device polls too often
+	ErrSlowDown = errors.New("device polls too often")
+	// ErrDenied is returned when the user denied the authorization
+	ErrDenied = errors.New("authorization is denied")
+)
+
+// Status of an authorization
+const (
+	Pending  = "pending"
+	Approved = "approved"
+	Denied   = "denied"
+)
+
+// Authorization is a request of a device for a token
+type Authorization struct {
+	DeviceCode string
+	UserCode   string
+	ClientID   string
+	Scopes     []string
+	Status     string
+	// User who approved or denied the authorization
+	UserID    int64
+	ExpiresAt time.Time
+	// Minimum time between two polls of the device
+	Interval time.Duration
+	lastPoll time.Time
+}
+
+// Store keeps the authorizations until they expire
+type Store struct {
+	mu        sync.Mutex
+	byDevice  map[string]*Authorization
+	byUser    map[string]*Authorization
+	ttl       time.Duration
+	interval  time.Duration
+	lastPrune time.Time
+}
+
+// NewStore returns a Store for authorizations that are valid for ttl,
+// the device should poll once in every interval
+func NewStore(ttl, interval time.Duration) *Store {
+	return &Store{
+		byDevice: make(map[string]*Authorization),
+		byUser:   make(map[string]*Authorization),
+		ttl:      ttl,
+		interval: interval,
+	}
+}
+
+// Create starts a new authorization for the client
+func (s *Store) Create(clientID string, scopes []string) (*Authorization, error) {
+	deviceCode, err := randomDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	var userCode string
+	for {
+		userCode, err = randomUserCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s.byUser[userCode]; !ok {
+			break
+		}
+	}
+	a := &Authorization{
+		DeviceCode: deviceCode,
+		UserCode:   userCode,
+		ClientID:   clientID,
+		Scopes:     scopes,
+		Status:     Pending,
+		ExpiresAt:  time.Now().Add(s.ttl),
+		Interval:   s.interval,
+	}
+	s.byDevice[deviceCode] = a
+	s.byUser[userCode] = a
+	return a, nil
+}
+
+// ByUserCode returns a copy of the pending authorization with the user
+// code, the code is matched without case and dashes
+func (s *Store) ByUserCode(userCode string) (*Authorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.pending(userCode)
+	if err != nil {
+		return nil, err
+	}
+	c := *a
+	return &c, nil
+}
+
+// Decide records the decision of the user for the pending authorization
+func (s *Store) Decide(userCode string, userID int64, approve bool) (*Authorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.pending(userCode)
+	if err != nil {
+		return nil, err
+	}
+	a.UserID = userID
+	a.Status = Denied
+	if approve {
+		a.Status = Approved
+	}
+	c := *a
+	return &c, nil
+}
+
+// Poll returns the approved authorization of the device and removes it,
+// so that a token is issued only once. Otherwise the error tells the
+// device what to do.
+func (s *Store) Poll(deviceCode, clientID string) (*Authorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.byDevice[deviceCode]
+	if !ok || a.ClientID != clientID {
+		return nil, ErrNotFound
+	}
+	now := time.Now()
+	if now.After(a.ExpiresAt) {
+		s.remove(a)
+		return nil, ErrExpired
+	}
+	switch a.Status {
+	case Approved:
+		s.remove(a)
+		return a, nil
+	case Denied:
+		s.remove(a)
+		return nil, ErrDenied
+	}
+	if !a.lastPoll.IsZero() && now.Sub(a.lastPoll) < a.Interval {
+		a.Interval += 5 * time.Second
+		a.lastPoll = now
+		return nil, ErrSlowDown
+	}
+	a.lastPoll = now
+	return nil, ErrPending
+}
+
+func (s *Store) pending(userCode string) (*Authorization, error) {
+	a, ok := s.byUser[NormalizeUserCode(userCode)]
+	if !ok || a.Status != Pending {
+		return nil, ErrNotFound
+	}
+	if time.Now().After(a.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return a, nil
+}
+
+func (s *Store) remove(a *Authorization) {
+	delete(s.byDevice, a.DeviceCode)
+	delete(s.byUser, a.UserCode)
+}
+
+// removes the expired authorizations, at most once a minute
+func (s *Store) prune() {
+	now := time.Now()
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for _, a := range s.byDevice {
+		if now.After(a.ExpiresAt) {
+			s.remove(a)
+		}
+	}
+}
+
+// NormalizeUserCode converts the code typed by the user to the stored
+// form, XXXX-XXXX in upper case
+func NormalizeUserCode(code string) string {
+	code = strings.ToUpper(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	if len(code) != 8 {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+func randomUserCode() (string, error) {
+	b := make([]byte, 8)
+	max := big.NewInt(int64(len(userCodeChars)))
+	for i := range b {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = userCodeChars[v.Int64()]
+	}
+	return NormalizeUserCode(string(b)), nil
+}
+
+func randomDeviceCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
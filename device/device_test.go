@@ -0,0 +1,79 @@
+package device
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeUserCode(t *testing.T) {
+	cases := []struct {
+		code string
+		want string
+	}{
+		{"BCDF-GHJK", "BCDF-GHJK"},
+		{"bcdfghjk", "BCDF-GHJK"},
+		{" bcdf-ghjk ", "BCDF-GHJK"},
+		{"bcd", "BCD"},
+	}
+	for _, c := range cases {
+		if got := NormalizeUserCode(c.code); got != c.want {
+			t.Errorf("%q: expected %q got %q", c.code, c.want, got)
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	s := NewStore(time.Minute, time.Hour)
+	a, err := s.Create("cli", []string{"openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Poll(a.DeviceCode, "other"); err != ErrNotFound {
+		t.Errorf("expected not found for other client got %v", err)
+	}
+	if _, err := s.Poll(a.DeviceCode, "cli"); err != ErrPending {
+		t.Errorf("expected pending got %v", err)
+	}
+	if _, err := s.Poll(a.DeviceCode, "cli"); err != ErrSlowDown {
+		t.Errorf("expected slow down got %v", err)
+	}
+	if got, _ := s.ByUserCode(a.UserCode); got.Interval != time.Hour+5*time.Second {
+		t.Errorf("expected interval to grow by 5s got %s", got.Interval)
+	}
+	if _, err := s.Decide(strings.ToLower(a.UserCode), 42, true); err != nil {
+		t.Fatalf("unable to approve %s", err)
+	}
+	if _, err := s.Decide(a.UserCode, 42, false); err != ErrNotFound {
+		t.Errorf("expected decided authorization to be not pending got %v", err)
+	}
+	got, err := s.Poll(a.DeviceCode, "cli")
+	if err != nil || got.UserID != 42 || got.Status != Approved {
+		t.Fatalf("expected approved authorization got %v %v", got, err)
+	}
+	if _, err := s.Poll(a.DeviceCode, "cli"); err != ErrNotFound {
+		t.Errorf("expected authorization to be used once got %v", err)
+	}
+}
+
+func TestPollDeniedAndExpired(t *testing.T) {
+	s := NewStore(time.Minute, 0)
+	a, _ := s.Create("cli", nil)
+	if _, err := s.Decide(a.UserCode, 42, false); err != nil {
+		t.Fatalf("unable to deny %s", err)
+	}
+	if _, err := s.Poll(a.DeviceCode, "cli"); err != ErrDenied {
+		t.Errorf("expected denied got %v", err)
+	}
+	if _, err := s.Poll(a.DeviceCode, "cli"); err != ErrNotFound {
+		t.Errorf("expected denied authorization to be removed got %v", err)
+	}
+	s = NewStore(-time.Second, 0)
+	a, _ = s.Create("cli", nil)
+	if _, err := s.ByUserCode(a.UserCode); err != ErrExpired {
+		t.Errorf("expected expired user code got %v", err)
+	}
+	if _, err := s.Poll(a.DeviceCode, "cli"); err != ErrExpired {
+		t.Errorf("expected expired got %v", err)
+	}
+}
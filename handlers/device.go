@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/device"
+	"github.com/dictyBase/authserver/metrics"
+	"github.com/dictyBase/authserver/middlewares"
+)
+
+// DeviceCodeGrantType is the grant type for polling the token endpoint
+// in the device flow
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceCodeResponse is the response of the device authorization endpoint
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceAttributes are the attributes of a pending device authorization
+// that are shown to the user before approval
+type DeviceAttributes struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name,omitempty"`
+	Scopes     []string  `json:"scopes"`
+	Status     string    `json:"status"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// DeviceResource is a pending device authorization in JSON:API format
+type DeviceResource struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Attributes *DeviceAttributes `json:"attributes"`
+}
+
+// DeviceCodeHandler starts the device flow for a client, the device shows
+// the user code and polls the token endpoint with the device code
+func (o *OAuth) DeviceCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthInvalidRequest.New("unable to parse form %s", err))
+		return
+	}
+	id := r.PostForm.Get("client_id")
+	cl, ok := o.Clients.Lookup(id)
+	if len(id) == 0 || !ok {
+		apierror.WriteOAuthError(w, r, apierror.OAuthInvalidClient.New("client %q is not registered", id))
+		return
+	}
+	scopes, err := GrantScopes(cl.Scopes, r.PostForm.Get("scope"))
+	if err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthInvalidScope.New("%s", err))
+		return
+	}
+	a, err := o.Devices.Create(cl.ID, scopes)
+	if err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthServerError.New("unable to create device code %s", err))
+		return
+	}
+	b, err := json.Marshal(&DeviceCodeResponse{
+		DeviceCode:              a.DeviceCode,
+		UserCode:                a.UserCode,
+		VerificationURI:         o.VerificationURI,
+		VerificationURIComplete: appendQuery(o.VerificationURI, url.Values{"user_code": {a.UserCode}}),
+		ExpiresIn:               int64(time.Until(a.ExpiresAt).Seconds()),
+		Interval:                int64(a.Interval.Seconds()),
+	})
+	if err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthServerError.New("%s", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(b)
+}
+
+// DeviceInfoHandler returns the pending authorization of the user code,
+// for showing the client and the scopes to the user before approval
+func (o *OAuth) DeviceInfoHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := o.Devices.ByUserCode(r.URL.Query().Get("user_code"))
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrDeviceCodeNotFound.New("%s", err))
+		return
+	}
+	writeJSONAPI(w, r, http.StatusOK, o.deviceResource(a))
+}
+
+type deviceDecision struct {
+	Data struct {
+		Attributes struct {
+			UserCode string `json:"user_code"`
+			Approve  bool   `json:"approve"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// DeviceDecisionHandler records the approval or denial of the
+// authorization by the logged in user
+func (o *OAuth) DeviceDecisionHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	req := &deviceDecision{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidParam.New("unable to decode request body %s", err))
+		return
+	}
+	a, err := o.Devices.Decide(req.Data.Attributes.UserCode, uid, req.Data.Attributes.Approve)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrDeviceCodeNotFound.New("%s", err))
+		return
+	}
+	ev := audit.NewEvent(r, audit.ActionDevice, audit.Denied)
+	ev.UserID = uid
+	ev.ClientID = a.ClientID
+	ev.Reason = "denied by user"
+	if a.Status == device.Approved {
+		ev.Outcome = audit.Validated
+		ev.Reason = "approved by user"
+	}
+	o.Auditor.Record(ev)
+	writeJSONAPI(w, r, http.StatusOK, o.deviceResource(a))
+}
+
+// DeviceCodeGrant issues the token to the device once the user
+// has approved the authorization
+func (o *OAuth) DeviceCodeGrant(w http.ResponseWriter, r *http.Request) {
+	grantType := "device_code"
+	clientID := r.PostForm.Get("client_id")
+	a, err := o.Devices.Poll(r.PostForm.Get("device_code"), clientID)
+	switch {
+	case errors.Is(err, device.ErrPending):
+		o.grantError(w, r, grantType, apierror.OAuthAuthorizationPending.New("%s", err))
+		return
+	case errors.Is(err, device.ErrSlowDown):
+		o.grantError(w, r, grantType, apierror.OAuthSlowDown.New("%s", err))
+		return
+	case errors.Is(err, device.ErrDenied):
+		o.grantError(w, r, grantType, apierror.OAuthAccessDenied.New("%s", err))
+		return
+	case errors.Is(err, device.ErrExpired):
+		o.grantError(w, r, grantType, apierror.OAuthExpiredToken.New("%s", err))
+		return
+	case err != nil:
+		o.grantError(w, r, grantType, apierror.OAuthInvalidGrant.New("%s", err))
+		return
+	}
+	ev := audit.NewEvent(r, audit.ActionDevice, audit.Denied)
+	ev.UserID = a.UserID
+	ev.ClientID = a.ClientID
+	cl, ok := o.Clients.Lookup(a.ClientID)
+	if !ok {
+		ev.Reason = "client is not registered"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidClient.New("client %s is not registered", a.ClientID))
+		return
+	}
+	claims, err := o.Users.UserClaims(r.Context(), cl, a.UserID)
+	if err != nil {
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, userClaimsError(err, apierror.OAuthAccessDenied))
+		return
+	}
+	claims.Scope = strings.Join(a.Scopes, " ")
+	if err := o.Users.startSession(claims, a.UserID, "device", cl.ID, ev, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		ev.Reason = "error in storing session"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthServerError.New("unable to store session %s", err))
+		return
+	}
+	res, err := o.NewTokenResponse(claims)
+	if err != nil {
+		ev.Reason = "error in signing token"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthServerError.New("error in signing token %s", err))
+		return
+	}
+	ev.Outcome = audit.Issued
+	ev.TokenID = claims.Id
+	o.Auditor.Record(ev)
+	metrics.RecordGrant(grantType, audit.Issued)
+	WriteTokenResponse(w, r, res)
+}
+
+func (o *OAuth) deviceResource(a *device.Authorization) *DeviceResource {
+	attr := &DeviceAttributes{
+		ClientID:  a.ClientID,
+		Scopes:    a.Scopes,
+		Status:    a.Status,
+		ExpiresAt: a.ExpiresAt,
+	}
+	if cl, ok := o.Clients.Lookup(a.ClientID); ok {
+		attr.ClientName = cl.Name
+	}
+	return &DeviceResource{Type: "device_authorizations", ID: a.UserCode, Attributes: attr}
+}
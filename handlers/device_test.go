@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/device"
+	"github.com/dictyBase/authserver/session"
+	"github.com/dictyBase/authserver/token"
+)
+
+func TestDeviceFlow(t *testing.T) {
+	j, o, fr := newTestHandlers(t, &client.Client{
+		ID:        "cli",
+		Name:      "Command line",
+		Audience:  token.DefaultAudience,
+		AccessTTL: time.Hour,
+		Scopes:    []string{"orders:read"},
+	})
+	fr.addUser(42, "ada@dictybase.org")
+	o.Devices = device.NewStore(time.Minute, time.Millisecond)
+	o.VerificationURI = "https://dictybase.org/device"
+	o.RegisterGrant(DeviceCodeGrantType, o.DeviceCodeGrant)
+	tokenHandler := http.HandlerFunc(o.TokenHandler)
+
+	w := postForm(http.HandlerFunc(o.DeviceCodeHandler), url.Values{"client_id": {"other"}})
+	if w.Code != http.StatusUnauthorized || oauthError(t, w) != "invalid_client" {
+		t.Errorf("expected invalid_client for an unknown client got %d %s", w.Code, w.Body.String())
+	}
+	w = postForm(http.HandlerFunc(o.DeviceCodeHandler), url.Values{"client_id": {"cli"}, "scope": {"orders:read"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the device code got %d %s", w.Code, w.Body.String())
+	}
+	code := &DeviceCodeResponse{}
+	decode(t, w, code)
+	if !strings.HasPrefix(code.VerificationURIComplete, o.VerificationURI+"?user_code=") {
+		t.Errorf("expected the user code in the verification uri got %s", code.VerificationURIComplete)
+	}
+	poll := url.Values{"grant_type": {DeviceCodeGrantType}, "client_id": {"cli"}, "device_code": {code.DeviceCode}}
+	if w := postForm(tokenHandler, poll); oauthError(t, w) != "authorization_pending" {
+		t.Errorf("expected authorization_pending before approval got %s", w.Body.String())
+	}
+
+	r := httptest.NewRequest("GET", "/oauth/device/verify?user_code="+url.QueryEscape(code.UserCode), nil)
+	w = httptest.NewRecorder()
+	o.DeviceInfoHandler(w, r)
+	info := struct {
+		Data *DeviceResource `json:"data"`
+	}{}
+	decode(t, w, &info)
+	if info.Data == nil || info.Data.Attributes.ClientName != "Command line" || info.Data.Attributes.Status != device.Pending {
+		t.Errorf("expected the pending authorization of the client got %s", w.Body.String())
+	}
+
+	body := `{"data": {"attributes": {"user_code": "` + code.UserCode + `", "approve": true}}}`
+	r = httptest.NewRequest("POST", "/oauth/device/verify", strings.NewReader(body))
+	w = serveWithToken(t, j, http.HandlerFunc(o.DeviceDecisionHandler), r, token.NewClaims(42, token.DefaultAudience, time.Hour))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the approval got %d %s", w.Code, w.Body.String())
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	w = postForm(tokenHandler, poll)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 after approval got %d %s", w.Code, w.Body.String())
+	}
+	res := &TokenResponse{}
+	decode(t, w, res)
+	claims, err := o.ParseToken(res.AccessToken)
+	if err != nil {
+		t.Fatalf("unable to parse token %s", err)
+	}
+	if claims.Subject != "42" || res.Scope != "orders:read" {
+		t.Errorf("expected the token of user 42 with orders:read got %s %s", claims.Subject, res.Scope)
+	}
+	if _, err := session.Check(j.Sessions, claims.SessionID); err != nil {
+		t.Errorf("expected an active session for the token got %s", err)
+	}
+	if w := postForm(tokenHandler, poll); oauthError(t, w) != "invalid_grant" {
+		t.Errorf("expected invalid_grant for a used device code got %s", w.Body.String())
+	}
+}
+
+func TestDeviceDenied(t *testing.T) {
+	j, o, _ := newTestHandlers(t, &client.Client{ID: "cli"})
+	o.Devices = device.NewStore(time.Minute, time.Millisecond)
+	o.RegisterGrant(DeviceCodeGrantType, o.DeviceCodeGrant)
+	a, err := o.Devices.Create("cli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"data": {"attributes": {"user_code": "` + a.UserCode + `", "approve": false}}}`
+	r := httptest.NewRequest("POST", "/oauth/device/verify", strings.NewReader(body))
+	if w := serveWithToken(t, j, http.HandlerFunc(o.DeviceDecisionHandler), r, token.NewClaims(42, token.DefaultAudience, time.Hour)); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the denial got %d %s", w.Code, w.Body.String())
+	}
+	w := postForm(
+		http.HandlerFunc(o.TokenHandler),
+		url.Values{"grant_type": {DeviceCodeGrantType}, "client_id": {"cli"}, "device_code": {a.DeviceCode}},
+	)
+	if oauthError(t, w) != "access_denied" {
+		t.Errorf("expected access_denied after denial got %s", w.Body.String())
+	}
+}
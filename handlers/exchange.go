@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/metrics"
+)
+
+// Identifiers of the token exchange grant(RFC 8693)
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	AccessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	JWTTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeGrant converts a token issued by the server into a
+// short lived token for the audience of a resource, only for the
+// confidential clients and the resources they are allowed to exchange
+// for. The scopes are narrowed to the ones of both the resource and the
+// subject token, which should have at least one in common. A token that
+// is already for a resource could not be exchanged.
+func (o *OAuth) TokenExchangeGrant(w http.ResponseWriter, r *http.Request) {
+	grantType := "token_exchange"
+	ev := audit.NewEvent(r, audit.ActionTokenExchange, audit.Denied)
+	cl, err := o.Clients.Authenticate(r, o.assertionAudience("/oauth/token"))
+	if err != nil {
+		ev.ClientID = r.PostForm.Get("client_id")
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidClient.New("%s", err))
+		return
+	}
+	ev.ClientID = cl.ID
+	switch r.PostForm.Get("subject_token_type") {
+	case AccessTokenType, JWTTokenType:
+	default:
+		ev.Reason = "unsupported subject token type"
+		o.Auditor.Record(ev)
+		o.grantError(
+			w, r, grantType,
+			apierror.OAuthInvalidRequest.New("subject_token_type %q is not supported", r.PostForm.Get("subject_token_type")),
+		)
+		return
+	}
+	if tt := r.PostForm.Get("requested_token_type"); len(tt) > 0 && tt != AccessTokenType {
+		ev.Reason = "unsupported requested token type"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidRequest.New("requested_token_type %q is not supported", tt))
+		return
+	}
+	subject, err := o.ParseToken(r.PostForm.Get("subject_token"))
+	if err != nil {
+		ev.Reason = "invalid subject token"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidGrant.New("invalid subject token %s", err))
+		return
+	}
+	ev.TokenID = subject.Id
+	if _, ok := o.Resources.ByAudience(subject.Audience); ok {
+		ev.Reason = "subject token is for a resource"
+		o.Auditor.Record(ev)
+		o.grantError(
+			w, r, grantType,
+			apierror.OAuthInvalidGrant.New("subject token is for the resource %s and could not be exchanged", subject.Audience),
+		)
+		return
+	}
+	// the exchanged token keeps the admin of an impersonation token
+	if subject.Actor != nil {
+		if aid, err := strconv.ParseInt(subject.Actor.Subject, 10, 64); err == nil {
+			ev.ActorID = aid
+		}
+	}
+	audience := r.PostForm.Get("audience")
+	res, ok := o.Resources.ByAudience(audience)
+	if !ok {
+		ev.Reason = "unknown audience"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidTarget.New("audience %q is not a registered resource", audience))
+		return
+	}
+	if !cl.AllowsExchange(res.Audience) {
+		ev.Reason = "audience is not allowed for the client"
+		o.Auditor.Record(ev)
+		o.grantError(
+			w, r, grantType,
+			apierror.OAuthUnauthorizedClient.New("client %s could not exchange tokens for %s", cl.ID, res.Audience),
+		)
+		return
+	}
+	common := intersect(res.Scopes, strings.Fields(subject.Scope))
+	if len(common) == 0 {
+		ev.Reason = "no common scope"
+		o.Auditor.Record(ev)
+		o.grantError(
+			w, r, grantType,
+			apierror.OAuthInvalidScope.New("subject token has none of the scopes of the resource %s", res.Audience),
+		)
+		return
+	}
+	scopes, err := GrantScopes(common, r.PostForm.Get("scope"))
+	if err != nil {
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidScope.New("%s", err))
+		return
+	}
+	claims := subject.Exchange(res.Audience, res.TokenTTL)
+	claims.ClientID = cl.ID
+	claims.Scope = strings.Join(scopes, " ")
+	tr, err := o.NewTokenResponse(claims)
+	if err != nil {
+		ev.Reason = "error in signing token"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthServerError.New("error in signing token %s", err))
+		return
+	}
+	tr.IssuedTokenType = AccessTokenType
+	ev.Outcome = audit.Issued
+	ev.TokenID = claims.Id
+	o.Auditor.Record(ev)
+	metrics.RecordGrant(grantType, audit.Issued)
+	WriteTokenResponse(w, r, tr)
+}
+
+// Returns the elements of a that are also in b
+func intersect(a, b []string) []string {
+	var common []string
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				common = append(common, x)
+				break
+			}
+		}
+	}
+	return common
+}
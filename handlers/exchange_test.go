@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/resource"
+	"github.com/dictyBase/authserver/token"
+)
+
+func TestTokenExchangeGrant(t *testing.T) {
+	j, o, _ := newTestHandlers(t,
+		&client.Client{
+			ID:                "stock",
+			SecretHash:        secretHash("secret"),
+			ExchangeAudiences: []string{"stock-orders"},
+		},
+		&client.Client{ID: "web"},
+	)
+	o.Resources = resource.NewRegistry([]*resource.Resource{
+		{Audience: "stock-orders", Scopes: []string{"orders:read", "orders:write"}, TokenTTL: 5 * time.Minute},
+		{Audience: "curation", Scopes: []string{"orders:read"}, TokenTTL: 5 * time.Minute},
+	})
+	o.RegisterGrant(TokenExchangeGrantType, o.TokenExchangeGrant)
+	sign := func(aud, scope string) string {
+		claims := token.NewClaims(42, aud, time.Hour)
+		claims.Scope = scope
+		raw, err := token.Sign(j.SignKey, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	subject := sign(token.DefaultAudience, "orders:read profile")
+	form := func(subject, audience string) url.Values {
+		return url.Values{
+			"grant_type":         {TokenExchangeGrantType},
+			"subject_token":      {subject},
+			"subject_token_type": {AccessTokenType},
+			"audience":           {audience},
+		}
+	}
+	cases := []struct {
+		name   string
+		form   url.Values
+		setup  []func(*http.Request)
+		status int
+		err    string
+	}{
+		{"exchange", form(subject, "stock-orders"), []func(*http.Request){basicAuth("stock", "secret")}, http.StatusOK, ""},
+		{"public client", form(subject, "stock-orders"), []func(*http.Request){basicAuth("web", "")}, http.StatusUnauthorized, "invalid_client"},
+		{
+			"audience of another client",
+			form(subject, "curation"),
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusBadRequest, "unauthorized_client",
+		},
+		{
+			"unknown audience",
+			form(subject, "billing"),
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusBadRequest, "invalid_target",
+		},
+		{
+			"no common scope",
+			form(sign(token.DefaultAudience, "profile"), "stock-orders"),
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusBadRequest, "invalid_scope",
+		},
+		{
+			"subject for a resource",
+			form(sign("stock-orders", "orders:read"), "stock-orders"),
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusBadRequest, "invalid_grant",
+		},
+		{
+			"invalid subject",
+			form("not-a-token", "stock-orders"),
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusBadRequest, "invalid_grant",
+		},
+	}
+	for _, c := range cases {
+		w := postForm(http.HandlerFunc(o.TokenHandler), c.form, c.setup...)
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d got %d %s", c.name, c.status, w.Code, w.Body.String())
+			continue
+		}
+		if len(c.err) > 0 {
+			if got := oauthError(t, w); got != c.err {
+				t.Errorf("%s: expected error %s got %s", c.name, c.err, got)
+			}
+			continue
+		}
+		res := &TokenResponse{}
+		decode(t, w, res)
+		claims, err := o.ParseToken(res.AccessToken)
+		if err != nil {
+			t.Fatalf("%s: unable to parse token %s", c.name, err)
+		}
+		if claims.Subject != "42" || claims.Audience != "stock-orders" || claims.ClientID != "stock" {
+			t.Errorf("%s: unexpected claims %+v", c.name, claims)
+		}
+		if res.Scope != "orders:read" || res.IssuedTokenType != AccessTokenType {
+			t.Errorf("%s: expected orders:read as an access token got %q %q", c.name, res.Scope, res.IssuedTokenType)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/message"
+	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/session"
+	"github.com/dictyBase/authserver/token"
+	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
+	"github.com/go-chi/jwtauth"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Answers the requests of the handlers from the users and their roles,
+// the unknown users are not found
+type fakeRequest struct {
+	users map[int64]*user.User
+	roles map[int64][]string
+}
+
+func newFakeRequest() *fakeRequest {
+	return &fakeRequest{
+		users: make(map[int64]*user.User),
+		roles: make(map[int64][]string),
+	}
+}
+
+func (f *fakeRequest) addUser(id int64, email string, roles ...string) {
+	f.users[id] = &user.User{Data: &user.UserData{
+		Type: "users",
+		Id:   id,
+		Attributes: &user.UserAttributes{
+			FirstName: "Ada",
+			LastName:  "Lovelace",
+			Email:     email,
+			IsActive:  true,
+		},
+	}}
+	f.roles[id] = roles
+}
+
+func (f *fakeRequest) IsActive() bool {
+	return true
+}
+
+func (f *fakeRequest) Drain(context.Context) error {
+	return nil
+}
+
+func (f *fakeRequest) UserRequest(subj string, r *pubsub.IdRequest, timeout time.Duration) (*pubsub.UserReply, error) {
+	return f.UserRequestWithContext(context.Background(), subj, r)
+}
+
+func (f *fakeRequest) UserRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*pubsub.UserReply, error) {
+	u, ok := f.users[r.Id]
+	if !ok {
+		return &pubsub.UserReply{Exist: false, Status: notFound()}, nil
+	}
+	return &pubsub.UserReply{Exist: true, User: u}, nil
+}
+
+func (f *fakeRequest) IdentityRequest(subj string, r *pubsub.IdentityReq, timeout time.Duration) (*pubsub.IdentityReply, error) {
+	return f.IdentityRequestWithContext(context.Background(), subj, r)
+}
+
+func (f *fakeRequest) IdentityRequestWithContext(ctx context.Context, subj string, r *pubsub.IdentityReq) (*pubsub.IdentityReply, error) {
+	return &pubsub.IdentityReply{Exist: false, Status: notFound()}, nil
+}
+
+func (f *fakeRequest) RoleRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*user.RoleCollection, error) {
+	rc := &user.RoleCollection{}
+	for i, role := range f.roles[r.Id] {
+		rc.Data = append(rc.Data, &user.RoleCollection_Data{
+			Type:       "roles",
+			Id:         int64(i + 1),
+			Attributes: &user.RoleAttributes{Role: role},
+		})
+	}
+	return rc, nil
+}
+
+func (f *fakeRequest) PermissionRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*user.PermissionCollection, error) {
+	return &user.PermissionCollection{}, nil
+}
+
+func notFound() *rpcstatus.Status {
+	return status.New(codes.NotFound, "not found").Proto()
+}
+
+func testKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func secretHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the handlers of the users and the oauth2 endpoints with the
+// fake messaging and the clients
+func newTestHandlers(t *testing.T, clients ...*client.Client) (*Jwt, *OAuth, *fakeRequest) {
+	key := testKey(t)
+	fr := newFakeRequest()
+	j := &Jwt{
+		SignKey:   key,
+		VerifyKey: key.Public(),
+		Request:   fr,
+		Topics:    message.DefaultTopics(),
+		Auditor:   audit.NewAuditor(),
+		Sessions:  session.NewMemoryStore(),
+	}
+	o := &OAuth{
+		SignKey:   key,
+		VerifyKey: key.Public(),
+		Clients:   client.NewRegistry(clients),
+		Auditor:   j.Auditor,
+		Users:     j,
+		IssuerURL: "https://auth.dictybase.org",
+	}
+	return j, o, fr
+}
+
+// Posts the form to the handler
+func postForm(h http.Handler, form url.Values, setup ...func(*http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, fn := range setup {
+		fn(r)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func basicAuth(id, secret string) func(*http.Request) {
+	return func(r *http.Request) {
+		r.SetBasicAuth(id, secret)
+	}
+}
+
+// Decodes the json response into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("unable to decode response %s %s", err, w.Body.String())
+	}
+}
+
+// Returns the error code of an oauth2 error response
+func oauthError(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	res := struct {
+		Error string `json:"error"`
+	}{}
+	decode(t, w, &res)
+	return res.Error
+}
+
+// Serves the request with the bearer token of the claims through the
+// verifier and the user authentication, as the routes of the server do
+func serveWithToken(t *testing.T, j *Jwt, h http.Handler, r *http.Request, claims *token.Claims) *httptest.ResponseRecorder {
+	t.Helper()
+	raw, err := token.Sign(j.SignKey, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "BEARER "+raw)
+	chain := jwtauth.Verifier(jwtauth.New("ES256", j.SignKey, j.VerifyKey))(
+		middlewares.UserAuthenticator([]string{token.DefaultAudience})(h),
+	)
+	w := httptest.NewRecorder()
+	chain.ServeHTTP(w, r)
+	return w
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/token"
+	"github.com/go-chi/chi"
+)
+
+// Impersonation issues the tokens that let the admins see what a user
+// sees, every attempt is audited
+type Impersonation struct {
+	Users   *Jwt
+	Auditor *audit.Auditor
+	// Role of the admins, the users with the role could not be
+	// impersonated
+	AdminRole string
+	TokenTTL  time.Duration
+}
+
+// ImpersonationAttributes are the attributes of an impersonation token
+// resource
+type ImpersonationAttributes struct {
+	Token     string    `json:"token"`
+	UserID    int64     `json:"user_id"`
+	ActorID   int64     `json:"actor_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ImpersonationResource is an impersonation token in JSON:API format
+type ImpersonationResource struct {
+	Type       string                   `json:"type"`
+	ID         string                   `json:"id"`
+	Attributes *ImpersonationAttributes `json:"attributes"`
+}
+
+// ImpersonateHandler issues a short lived token of the user in the path
+// to the admin of the bearer token, the token names the admin in its act
+// claim. The roles of both the users are looked up again, so that a
+// revoked admin could not impersonate and no admin is impersonated.
+func (im *Impersonation) ImpersonateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ev := audit.NewEvent(r, audit.ActionImpersonation, audit.Denied)
+	aid, ok := middlewares.UserIDFromContext(ctx)
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	ev.ActorID = aid
+	uid, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		ev.Reason = "invalid user id"
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidParam.New("invalid user id %q", chi.URLParam(r, "id")))
+		return
+	}
+	ev.UserID = uid
+	if uid == aid {
+		ev.Reason = "admin could not impersonate itself"
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidParam.New("user %d is the admin of the token", uid))
+		return
+	}
+	roles, _, err := im.Users.Roles(ctx, aid)
+	if err != nil {
+		ev.Reason = failureReason("role", true, err)
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrMessaging.New("error in getting roles %s", err))
+		return
+	}
+	if !hasRole(roles, im.AdminRole) {
+		ev.Reason = "user is not an admin"
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(
+			w, r,
+			apierror.ErrImpersonationNotAllowed.New("user %d does not have the role %s", aid, im.AdminRole),
+		)
+		return
+	}
+	if _, aerr := im.Users.LookupUser(ctx, uid); aerr != nil {
+		ev.Reason = aerr.Error()
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, aerr)
+		return
+	}
+	roles, perms, err := im.Users.Roles(ctx, uid)
+	if err != nil {
+		ev.Reason = failureReason("role", true, err)
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrMessaging.New("error in getting roles %s", err))
+		return
+	}
+	if hasRole(roles, im.AdminRole) {
+		ev.Reason = "user is an admin"
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(
+			w, r,
+			apierror.ErrImpersonationNotAllowed.New("user %d has the role %s and could not be impersonated", uid, im.AdminRole),
+		)
+		return
+	}
+	claims := token.NewClaims(uid, token.DefaultAudience, im.TokenTTL)
+	claims.Roles = roles
+	claims.SetPermissions(perms, im.Users.MaxPermissionsSize)
+	claims.Actor = &token.Actor{Subject: strconv.FormatInt(aid, 10)}
+	tkn, err := token.Sign(im.Users.SignKey, claims)
+	if err != nil {
+		ev.Reason = "error in signing token"
+		im.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrTokenSigning.New("error in signing jwt token %s", err))
+		return
+	}
+	ev.Outcome = audit.Issued
+	ev.TokenID = claims.Id
+	im.Auditor.Record(ev)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSONAPI(w, r, http.StatusCreated, &ImpersonationResource{
+		Type: "impersonation_tokens",
+		ID:   claims.Id,
+		Attributes: &ImpersonationAttributes{
+			Token:     tkn,
+			UserID:    uid,
+			ActorID:   aid,
+			ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+		},
+	})
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/dictyBase/authserver/token"
+	"github.com/go-chi/chi"
+)
+
+func TestImpersonateHandler(t *testing.T) {
+	j, _, fr := newTestHandlers(t)
+	fr.addUser(1, "admin@dictybase.org", "admin")
+	fr.addUser(2, "curator@dictybase.org", "curator")
+	fr.addUser(3, "other@dictybase.org", "admin")
+	fr.addUser(42, "ada@dictybase.org", "user")
+	im := &Impersonation{
+		Users:     j,
+		Auditor:   j.Auditor,
+		AdminRole: "admin",
+		TokenTTL:  15 * time.Minute,
+	}
+	router := chi.NewRouter()
+	router.Post("/users/{id}/impersonation", im.ImpersonateHandler)
+	cases := []struct {
+		name   string
+		actor  int64
+		target string
+		status int
+	}{
+		{"not an admin", 2, "42", http.StatusForbidden},
+		{"admin as target", 1, "3", http.StatusForbidden},
+		{"self", 1, "1", http.StatusBadRequest},
+		{"invalid id", 1, "ada", http.StatusBadRequest},
+		{"unknown user", 1, "7", http.StatusUnauthorized},
+		{"impersonate", 1, "42", http.StatusCreated},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/users/"+c.target+"/impersonation", nil)
+		w := serveWithToken(t, j, router, r, token.NewClaims(c.actor, token.DefaultAudience, time.Hour))
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d got %d %s", c.name, c.status, w.Code, w.Body.String())
+			continue
+		}
+		if c.status != http.StatusCreated {
+			continue
+		}
+		res := struct {
+			Data *ImpersonationResource `json:"data"`
+		}{}
+		decode(t, w, &res)
+		claims := &token.Claims{}
+		_, err := jwt.ParseWithClaims(res.Data.Attributes.Token, claims, func(*jwt.Token) (interface{}, error) {
+			return j.VerifyKey, nil
+		})
+		if err != nil {
+			t.Fatalf("%s: unable to parse token %s", c.name, err)
+		}
+		if claims.Subject != "42" || claims.Actor == nil || claims.Actor.Subject != "1" {
+			t.Errorf("%s: expected a token of user 42 acted by 1 got %+v", c.name, claims)
+		}
+		if exp := time.Unix(claims.ExpiresAt, 0); exp.After(time.Now().Add(im.TokenTTL)) {
+			t.Errorf("%s: expected the token to expire within %s got %s", c.name, im.TokenTTL, exp)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/pat"
+	"github.com/dictyBase/authserver/token"
+)
+
+func TestIntrospectHandler(t *testing.T) {
+	j, o, _ := newTestHandlers(t, &client.Client{ID: "stock", SecretHash: secretHash("secret")})
+	o.PersonalTokens = pat.NewMemoryStore()
+	raw, pt, err := pat.New(42, "script", []string{"orders:read"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.PersonalTokens.Create(pt); err != nil {
+		t.Fatal(err)
+	}
+	active, err := token.Sign(j.SignKey, token.NewClaims(42, token.DefaultAudience, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := token.Sign(j.SignKey, token.NewClaims(42, token.DefaultAudience, -time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken, err := token.Sign(j.SignKey, token.NewIDClaims(42, o.IssuerURL, "web", time.Now(), time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.HandlerFunc(o.IntrospectHandler)
+	cases := []struct {
+		name      string
+		token     string
+		active    bool
+		tokenType string
+	}{
+		{"access token", active, true, "Bearer"},
+		{"personal access token", raw, true, "personal_access_token"},
+		{"expired token", expired, false, ""},
+		{"id token", idToken, false, ""},
+		{"garbage", "not-a-token", false, ""},
+	}
+	for _, c := range cases {
+		w := postForm(h, url.Values{"token": {c.token}}, basicAuth("stock", "secret"))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200 got %d %s", c.name, w.Code, w.Body.String())
+			continue
+		}
+		res := &Introspection{}
+		decode(t, w, res)
+		if res.Active != c.active || res.TokenType != c.tokenType {
+			t.Errorf("%s: expected active %t and type %q got %t %q", c.name, c.active, c.tokenType, res.Active, res.TokenType)
+		}
+		if res.Active && res.Sub != "42" {
+			t.Errorf("%s: expected subject 42 got %s", c.name, res.Sub)
+		}
+	}
+	w := postForm(h, url.Values{"token": {active}}, basicAuth("stock", "other"))
+	if w.Code != http.StatusUnauthorized || oauthError(t, w) != "invalid_client" {
+		t.Errorf("expected invalid_client for a wrong secret got %d %s", w.Code, w.Body.String())
+	}
+	w = postForm(h, url.Values{}, basicAuth("stock", "secret"))
+	if w.Code != http.StatusBadRequest || oauthError(t, w) != "invalid_request" {
+		t.Errorf("expected invalid_request without a token got %d %s", w.Code, w.Body.String())
+	}
+}
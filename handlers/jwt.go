@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"context"
-	"crypto/rsa"
+	"crypto"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc/status"
@@ -15,12 +18,17 @@ import (
 	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
 	pb "github.com/dictyBase/go-genproto/dictybaseapis/user"
 
-	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/client"
 	"github.com/dictyBase/authserver/message"
+	"github.com/dictyBase/authserver/metrics"
+	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/resource"
+	"github.com/dictyBase/authserver/session"
+	"github.com/dictyBase/authserver/token"
 	"github.com/dictyBase/authserver/user"
 	"github.com/go-chi/jwtauth"
-	"github.com/rs/xid"
 )
 
 type contextKey string
@@ -34,12 +42,29 @@ var (
 	ContextKeyUser = contextKey("user")
 )
 
+// ImpersonatedByHeader is set by /authorize to the admin who
+// impersonates the user of the token
+const ImpersonatedByHeader = "X-Impersonated-By"
+
 type Jwt struct {
-	VerifyKey     *rsa.PublicKey
-	SignKey       *rsa.PrivateKey
+	VerifyKey     crypto.PublicKey
+	SignKey       crypto.Signer
 	UserParamater string
 	Request       message.Request
-	Topics        map[string]string
+	Topics        message.Topics
+	Auditor       *audit.Auditor
+	// Size limit of the permissions in a token, zero has no limit
+	MaxPermissionsSize int
+	// Issue the tokens without roles and permissions when the
+	// permission service fails
+	RolesFallback bool
+	// Resources whose tokens are checked by /authorize
+	Resources *resource.Registry
+	// Login sessions that the tokens refer to
+	Sessions session.Store
+	// Accept the tokens of the sessions that are not in the store, for
+	// the memory store that loses them on restart
+	AcceptUnknownSessions bool
 }
 
 type AuthUser struct {
@@ -49,17 +74,77 @@ type AuthUser struct {
 }
 
 func (j *Jwt) JwtFinalHandler(w http.ResponseWriter, r *http.Request) {
-	token, _, err := jwtauth.FromContext(r.Context())
+	ev := audit.NewEvent(r, audit.ActionAuthorize, audit.Denied)
+	tkn, claims, err := jwtauth.FromContext(r.Context())
 	if err != nil {
 		log.Printf("error from jwt %s", err.Error())
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		metrics.RecordAuthorize(metrics.AuthorizeDenied)
+		ev.Reason = err.Error()
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("%s", err))
 		return
 	}
-	if token == nil || !token.Valid {
+	if tkn == nil || !tkn.Valid {
 		log.Println("invalid token")
-		http.Error(w, "invalid token", http.StatusUnauthorized)
+		metrics.RecordAuthorize(metrics.AuthorizeDenied)
+		ev.Reason = "invalid token"
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("invalid token"))
+		return
+	}
+	// id tokens are signed with the same key but are not access tokens
+	if iss, _ := claims["iss"].(string); iss != token.Issuer {
+		metrics.RecordAuthorize(metrics.AuthorizeDenied)
+		ev.Reason = "not an access token"
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("token is not an access token"))
 		return
 	}
+	if jti, ok := claims["jti"].(string); ok {
+		ev.TokenID = jti
+	}
+	if sub, ok := claims["sub"].(string); ok {
+		if uid, err := strconv.ParseInt(sub, 10, 64); err == nil {
+			ev.UserID = uid
+		}
+		middlewares.AddLogField(r.Context(), "user_id", sub)
+	}
+	// the ingress forwards the header to the services, so that they
+	// could tell the impersonated requests
+	act, impersonated := token.ActorFromMap(claims)
+	if impersonated {
+		if aid, err := strconv.ParseInt(act, 10, 64); err == nil {
+			ev.ActorID = aid
+		}
+		middlewares.AddLogField(r.Context(), "actor", act)
+	}
+	if sid, ok := claims["sid"].(string); ok {
+		ev.SessionID = sid
+		if err := j.checkSession(sid); err != nil {
+			metrics.RecordAuthorize(metrics.AuthorizeDenied)
+			ev.Reason = err.Error()
+			j.Auditor.Record(ev)
+			apierror.JSONAPIError(w, r, sessionError(err))
+			return
+		}
+	}
+	if res, ok := j.Resources.Match(r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Original-Uri")); ok {
+		aud, _ := claims["aud"].(string)
+		scope, _ := claims["scope"].(string)
+		if err := res.Check(aud, strings.Fields(scope)); err != nil {
+			metrics.RecordAuthorize(metrics.AuthorizeDenied)
+			ev.Reason = err.Error()
+			j.Auditor.Record(ev)
+			apierror.JSONAPIError(w, r, resourceError(err))
+			return
+		}
+	}
+	metrics.RecordAuthorize(metrics.AuthorizeAllowed)
+	ev.Outcome = audit.Validated
+	j.Auditor.Record(ev)
+	if impersonated {
+		w.Header().Set(ImpersonatedByHeader, act)
+	}
 	fmt.Fprintf(w, "jwt is %s", "valid")
 }
 
@@ -67,114 +152,215 @@ func (j *Jwt) JwtHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user, ok := ctx.Value(user.ContextKeyUser).(*user.NormalizedUser)
 	if !ok {
-		apherror.JSONAPIError(w, apherror.ErrReqContext.New("unable to retrieve %s from context", "user"))
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user"))
 		return
 	}
 	idnReq := &pubsub.IdentityReq{Provider: user.Provider, Identifier: user.Email}
 	if user.Provider == "orcid" {
 		idnReq.Identifier = user.Id
 	}
+	ev := audit.NewEvent(r, audit.ActionLogin, audit.Denied)
+	ev.Provider = user.Provider
+	ev.Identity = idnReq.Identifier
+	if oauthConf, ok := middlewares.OauthConfigFromContext(ctx); ok {
+		ev.ClientID = oauthConf.Config.ClientID
+	}
+	cl, ok := client.FromContext(ctx)
+	if !ok {
+		cl = client.Default(ev.ClientID)
+	}
+	// scopes of the token, not the ones of the provider
+	scopes, err := GrantScopes(cl.Scopes, r.FormValue("scope"))
+	if err != nil {
+		metrics.RecordLogin(user.Provider, metrics.LoginInvalidScope)
+		ev.Reason = err.Error()
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidScope.New("%s", err))
+		return
+	}
 	// check if the identity is present
 	idnReply, err := j.Request.IdentityRequestWithContext(
-		context.Background(),
-		j.Topics["identityGet"],
+		ctx,
+		j.Topics[message.IdentityGet],
 		idnReq,
 	)
-	if handleIdentityErr(w, idnReply, idnReq.Identifier, err) {
+	if handleIdentityErr(w, r, idnReply, idnReq.Identifier, user.Provider, err) {
+		ev.Reason = failureReason("identity", idnReply.Exist, err)
+		j.Auditor.Record(ev)
 		return
 	}
 	// Now check for user id
 	uid := idnReply.Identity.Data.Attributes.UserId
 	uReply, err := j.Request.UserRequestWithContext(
-		context.Background(),
-		j.Topics["userExists"],
+		ctx,
+		j.Topics[message.UserExists],
 		&pubsub.IdRequest{Id: uid},
 	)
-	if handleUserErr(w, uReply, uid, err) {
+	ev.UserID = uid
+	if handleUserErr(w, r, uReply, uid, user.Provider, err) {
+		ev.Reason = failureReason("user", uReply.Exist, err)
+		j.Auditor.Record(ev)
 		return
 	}
 	// Fetch the user
 	duReply, err := j.Request.UserRequestWithContext(
-		context.Background(),
-		j.Topics["userGet"],
+		ctx,
+		j.Topics[message.UserGet],
 		&pubsub.IdRequest{Id: uid},
 	)
-	if handleUserErr(w, duReply, uid, err) {
+	if handleUserErr(w, r, duReply, uid, user.Provider, err) {
+		ev.Reason = failureReason("user", duReply.Exist, err)
+		j.Auditor.Record(ev)
 		return
 	}
-
-	claims := jwt.StandardClaims{
-		Issuer:    "dictyBase",
-		Subject:   "dictyBase login token",
-		ExpiresAt: time.Now().Add(time.Hour * 240).Unix(),
-		IssuedAt:  time.Now().Unix(),
-		NotBefore: time.Now().Unix(),
-		Id:        xid.New().String(),
-		Audience:  "user",
+	claims, err := j.UserClaims(ctx, cl, uid)
+	if errors.Is(err, ErrMissingRole) {
+		metrics.RecordLogin(user.Provider, metrics.LoginRoleMissing)
+		ev.Reason = err.Error()
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(
+			w, r,
+			apierror.ErrMissingRole.New("user %d does not have any of the roles required by client %s", uid, cl.ID),
+		)
+		return
 	}
-
-	// create a signer for rsa 512
-	t := jwt.NewWithClaims(jwt.SigningMethodRS512, claims)
-	token, err := t.SignedString(j.SignKey)
 	if err != nil {
-		apherror.ErrJWTToken.New("error in signing jwt token %s", err.Error())
+		metrics.RecordLogin(user.Provider, metrics.LoginMessagingError)
+		ev.Reason = failureReason("role", true, err)
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrMessaging.New("%s", err))
 		return
 	}
+	claims.Scope = strings.Join(scopes, " ")
+	if err := j.startSession(claims, uid, user.Provider, cl.ID, ev, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		metrics.RecordLogin(user.Provider, metrics.LoginStoreError)
+		ev.Reason = "error in storing session"
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrStore.New("unable to store session %s", err))
+		return
+	}
+	tkn, err := token.Sign(j.SignKey, claims)
+	if err != nil {
+		metrics.RecordLogin(user.Provider, metrics.LoginTokenError)
+		ev.Reason = "error in signing token"
+		j.Auditor.Record(ev)
+		apierror.JSONAPIError(w, r, apierror.ErrTokenSigning.New("error in signing jwt token %s", err))
+		return
+	}
+	metrics.RecordLogin(user.Provider, metrics.LoginSuccess)
+	ev.Outcome = audit.Issued
+	ev.TokenID = claims.Id
+	j.Auditor.Record(ev)
+	middlewares.AddLogField(ctx, "user_id", uid)
 	auser := &AuthUser{
-		Token:    token,
+		Token:    tkn,
 		User:     duReply.User,
 		Identity: idnReply.Identity,
 	}
-	w.Header().Set("Content-Type", "application/vnd.api+json")
-	if err := json.NewEncoder(w).Encode(auser); err != nil {
-		apherror.ErrJSONEncoding.New(err.Error())
+	b, err := json.Marshal(auser)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrJSONEncoding.New("%s", err))
 		return
 	}
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.Write(b)
+}
+
+// ErrMissingRole is returned when the user does not have any of the
+// roles required by the client
+var ErrMissingRole = errors.New("user does not have a role required by the client")
+
+// UserClaims returns the claims of a token of the user for the client
+// with the roles and permissions of the user, every flow that issues a
+// token to a user gets its claims from here. With RolesFallback a failure
+// of the permission service gives a token without roles and permissions,
+// except for the clients that require a role.
+func (j *Jwt) UserClaims(ctx context.Context, cl *client.Client, uid int64) (*token.Claims, error) {
+	claims := token.NewClaims(uid, cl.Audience, cl.AccessTTL)
+	roles, perms, err := j.Roles(ctx, uid)
+	if err != nil {
+		if !j.RolesFallback || len(cl.RequiredRoles) > 0 {
+			return nil, fmt.Errorf("error in getting roles %w", err)
+		}
+		log.Printf("token of user %d is issued without roles, error in getting roles %s\n", uid, err)
+	}
+	claims.Roles = roles
+	claims.SetPermissions(perms, j.MaxPermissionsSize)
+	if !cl.HasRequiredRole(claims.Roles) {
+		return nil, ErrMissingRole
+	}
+	return claims, nil
+}
+
+// Returns the error of a token that is not accepted by a resource
+func resourceError(err error) *apierror.Error {
+	if errors.Is(err, resource.ErrAudience) {
+		return apierror.ErrAudienceNotAllowed.New("%s", err)
+	}
+	return apierror.ErrInsufficientScope.New("%s", err)
+}
+
+// Returns the reason of a failed lookup for the audit trail
+func failureReason(kind string, exist bool, err error) string {
+	switch {
+	case err != nil:
+		return fmt.Sprintf("%s lookup failed with messaging error", kind)
+	case !exist:
+		return fmt.Sprintf("%s is not registered", kind)
+	default:
+		return fmt.Sprintf("%s lookup failed", kind)
+	}
 }
 
-func handleUserErr(w http.ResponseWriter, reply *pubsub.UserReply, id int64, err error) bool {
+func handleUserErr(w http.ResponseWriter, r *http.Request, reply *pubsub.UserReply, id int64, provider string, err error) bool {
 	if err != nil {
-		apherror.JSONAPIError(w, apherror.ErrMessagingReply.New("error in getting user reply %s", err.Error()))
+		metrics.RecordLogin(provider, metrics.LoginMessagingError)
+		apierror.JSONAPIError(w, r, apierror.ErrMessaging.New("error in getting user reply %s", err))
 		return true
 	}
 	if reply.Status != nil {
 		if !reply.Exist {
+			metrics.RecordLogin(provider, metrics.LoginUserNotFound)
 			msg := "user is not registered or not linked with dictybase account"
 			w.Header().Set("WWW-Authenticate", msg)
-			apherror.JSONAPIError(
-				w,
-				apherror.ErrAuthentication.New(
-					"cannot authenticate user id %s with error %s",
+			apierror.JSONAPIError(
+				w, r,
+				apierror.ErrUserNotFound.New(
+					"cannot authenticate user id %d with error %s",
 					id,
 					status.ErrorProto(reply.Status).Error(),
 				))
 			return true
 		}
-		apherror.JSONAPIError(w, apherror.ErrMessagingReply.New(status.ErrorProto(reply.Status).Error()))
+		metrics.RecordLogin(provider, metrics.LoginMessagingError)
+		apierror.JSONAPIError(w, r, apierror.ErrMessaging.New("%s", status.ErrorProto(reply.Status)))
 		return true
 	}
 	return false
 }
 
-func handleIdentityErr(w http.ResponseWriter, reply *pubsub.IdentityReply, id string, err error) bool {
+func handleIdentityErr(w http.ResponseWriter, r *http.Request, reply *pubsub.IdentityReply, id, provider string, err error) bool {
 	if err != nil {
-		apherror.JSONAPIError(w, apherror.ErrMessagingReply.New("error in getting identifier reply %s", err.Error()))
+		metrics.RecordLogin(provider, metrics.LoginMessagingError)
+		apierror.JSONAPIError(w, r, apierror.ErrMessaging.New("error in getting identifier reply %s", err))
 		return true
 	}
 	if reply.Status != nil {
 		if !reply.Exist {
+			metrics.RecordLogin(provider, metrics.LoginIdentityNotFound)
 			msg := fmt.Sprintf("identity %s is not registered or not linked with dictybase account", id)
 			w.Header().Set("WWW-Authenticate", msg)
-			apherror.JSONAPIError(
-				w,
-				apherror.ErrAuthentication.New(
+			apierror.JSONAPIError(
+				w, r,
+				apierror.ErrIdentityNotFound.New(
 					"cannot authenticate identifier %s with error %s",
 					id,
 					status.ErrorProto(reply.Status).Error(),
 				))
 			return true
 		}
-		apherror.JSONAPIError(w, apherror.ErrMessagingReply.New(status.ErrorProto(reply.Status).Error()))
+		metrics.RecordLogin(provider, metrics.LoginMessagingError)
+		apierror.JSONAPIError(w, r, apierror.ErrMessaging.New("%s", status.ErrorProto(reply.Status)))
 		return true
 	}
 	return false
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/identity"
+	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
+	pb "github.com/dictyBase/go-genproto/dictybaseapis/user"
+	"google.golang.org/grpc/status"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/message"
+	"github.com/dictyBase/authserver/middlewares"
+)
+
+// Providers whose identities are identified by the email of the user,
+// the identities of orcid could not be looked up from the user
+var emailProviders = []string{"google", "facebook", "linkedin"}
+
+// ResourceID identifies a related resource in JSON:API format
+type ResourceID struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// UserRelationships links the user to its identities
+type UserRelationships struct {
+	Identities struct {
+		Data []*ResourceID `json:"data"`
+	} `json:"identities"`
+}
+
+// UserResource is the current user in JSON:API format
+type UserResource struct {
+	Type          string             `json:"type"`
+	ID            string             `json:"id"`
+	Attributes    *pb.UserAttributes `json:"attributes"`
+	Relationships *UserRelationships `json:"relationships"`
+}
+
+// IdentityResource is an identity of the user in JSON:API format
+type IdentityResource struct {
+	Type       string                       `json:"type"`
+	ID         string                       `json:"id"`
+	Attributes *identity.IdentityAttributes `json:"attributes"`
+}
+
+// identitiesDetail tells the clients which identities are missing from
+// the response
+const identitiesDetail = "only the identities of the providers registered with the email of the user are listed, the identities of orcid and the ones registered with another email are not"
+
+// IdentitiesMeta tells how the identities of the user were looked up,
+// the list is not complete
+type IdentitiesMeta struct {
+	Complete  bool     `json:"complete"`
+	Providers []string `json:"providers"`
+	Detail    string   `json:"detail"`
+}
+
+// UserMeta is the meta information of the current user response
+type UserMeta struct {
+	Identities *IdentitiesMeta `json:"identities"`
+}
+
+// UserDocument is the response of the current user endpoint with the
+// identities of the user included
+type UserDocument struct {
+	Data     *UserResource       `json:"data"`
+	Included []*IdentityResource `json:"included"`
+	Meta     *UserMeta           `json:"meta"`
+}
+
+// CurrentUserHandler returns the user of the bearer token with the
+// identities that are linked to it
+func (j *Jwt) CurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	u, aerr := j.LookupUser(r.Context(), uid)
+	if aerr != nil {
+		apierror.JSONAPIError(w, r, aerr)
+		return
+	}
+	idns, aerr := j.LookupIdentities(r.Context(), uid, u.Data.Attributes.Email)
+	if aerr != nil {
+		apierror.JSONAPIError(w, r, aerr)
+		return
+	}
+	doc := &UserDocument{
+		Data: &UserResource{
+			Type:          "users",
+			ID:            strconv.FormatInt(uid, 10),
+			Attributes:    u.Data.Attributes,
+			Relationships: &UserRelationships{},
+		},
+		Included: []*IdentityResource{},
+		Meta: &UserMeta{Identities: &IdentitiesMeta{
+			Providers: emailProviders,
+			Detail:    identitiesDetail,
+		}},
+	}
+	doc.Data.Relationships.Identities.Data = []*ResourceID{}
+	for _, idn := range idns {
+		id := strconv.FormatInt(idn.Data.Id, 10)
+		doc.Data.Relationships.Identities.Data = append(
+			doc.Data.Relationships.Identities.Data,
+			&ResourceID{Type: "identities", ID: id},
+		)
+		doc.Included = append(doc.Included, &IdentityResource{
+			Type:       "identities",
+			ID:         id,
+			Attributes: idn.Data.Attributes,
+		})
+	}
+	writeDocument(w, r, http.StatusOK, doc)
+}
+
+// LookupUser fetches the user from the user service, a user that is no
+// longer registered is reported as not found
+func (j *Jwt) LookupUser(ctx context.Context, uid int64) (*pb.User, *apierror.Error) {
+	reply, err := j.Request.UserRequestWithContext(
+		ctx,
+		j.Topics[message.UserGet],
+		&pubsub.IdRequest{Id: uid},
+	)
+	if err != nil {
+		return nil, apierror.ErrMessaging.New("error in getting user reply %s", err)
+	}
+	if reply.Status != nil {
+		if !reply.Exist {
+			return nil, apierror.ErrUserNotFound.New(
+				"user %d of the token is not registered %s",
+				uid, status.ErrorProto(reply.Status),
+			)
+		}
+		return nil, apierror.ErrMessaging.New("%s", status.ErrorProto(reply.Status))
+	}
+	if reply.User == nil || reply.User.Data == nil || reply.User.Data.Attributes == nil {
+		return nil, apierror.ErrMessaging.New("user service returned no data for user %d", uid)
+	}
+	return reply.User, nil
+}
+
+// LookupIdentities fetches the identities of the providers that are
+// identified by the email of the user, only the ones linked to the user
+// are returned
+func (j *Jwt) LookupIdentities(ctx context.Context, uid int64, email string) ([]*identity.Identity, *apierror.Error) {
+	var idns []*identity.Identity
+	if len(email) == 0 {
+		return idns, nil
+	}
+	for _, p := range emailProviders {
+		reply, err := j.Request.IdentityRequestWithContext(
+			ctx,
+			j.Topics[message.IdentityGet],
+			&pubsub.IdentityReq{Provider: p, Identifier: email},
+		)
+		if err != nil {
+			return nil, apierror.ErrMessaging.New("error in getting identifier reply %s", err)
+		}
+		if reply.Status != nil {
+			if !reply.Exist {
+				continue
+			}
+			return nil, apierror.ErrMessaging.New("%s", status.ErrorProto(reply.Status))
+		}
+		idn := reply.Identity
+		if idn == nil || idn.Data == nil || idn.Data.Attributes == nil || idn.Data.Attributes.UserId != uid {
+			continue
+		}
+		idns = append(idns, idn)
+	}
+	return idns, nil
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/device"
+	"github.com/dictyBase/authserver/metrics"
+	"github.com/dictyBase/authserver/oidc"
+	"github.com/dictyBase/authserver/pat"
+	"github.com/dictyBase/authserver/resource"
+	"github.com/dictyBase/authserver/token"
+)
+
+// OAuth serves the oauth2 token endpoint, every grant type is handled by
+// its own http.HandlerFunc that is registered with the endpoint
+type OAuth struct {
+	SignKey   crypto.Signer
+	VerifyKey crypto.PublicKey
+	Clients   *client.Registry
+	Auditor   *audit.Auditor
+	// Store of the personal access tokens for introspection
+	PersonalTokens pat.Store
+	// Issues the claims of the user tokens
+	Users *Jwt
+	// Pending authorizations of the device flow and the url of the
+	// page where the users enter the code
+	Devices         *device.Store
+	VerificationURI string
+	// Pending requests and codes of the authorization code flow, the
+	// refresh tokens and the url of the page where the users login
+	Authorizations *oidc.Store
+	RefreshTokens  *oidc.RefreshStore
+	LoginURI       string
+	// Lifetime of the id tokens
+	IDTokenTTL time.Duration
+	// Target resources of the token exchange
+	Resources *resource.Registry
+	// Public url of the server, the url of the token endpoint is
+	// derived from the request if it is empty. The audiences of the
+	// client assertions are built only from it.
+	IssuerURL string
+	grants    map[string]http.HandlerFunc
+}
+
+// TokenResponse is the successful response of the token endpoint
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	// Type of the token of a token exchange
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// RegisterGrant adds the handler for a grant type
+func (o *OAuth) RegisterGrant(grantType string, fn http.HandlerFunc) {
+	if o.grants == nil {
+		o.grants = make(map[string]http.HandlerFunc)
+	}
+	o.grants[grantType] = fn
+}
+
+// TokenHandler dispatches the request to the handler of its grant type
+func (o *OAuth) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthInvalidRequest.New("unable to parse form %s", err))
+		return
+	}
+	grantType := r.PostForm.Get("grant_type")
+	fn, ok := o.grants[grantType]
+	if !ok {
+		metrics.RecordGrant("unknown", apierror.OAuthUnsupportedGrantType.Code)
+		apierror.WriteOAuthError(w, r, apierror.OAuthUnsupportedGrantType.New("grant type %q is not supported", grantType))
+		return
+	}
+	fn(w, r)
+}
+
+// ClientCredentialsGrant issues a token to a confidential client for
+// calling the apis as itself, the subject of the token is the client
+func (o *OAuth) ClientCredentialsGrant(w http.ResponseWriter, r *http.Request) {
+	grantType := "client_credentials"
+	ev := audit.NewEvent(r, audit.ActionClientCredentials, audit.Denied)
+	cl, err := o.Clients.Authenticate(r, o.assertionAudience("/oauth/token"))
+	if err != nil {
+		ev.ClientID = r.PostForm.Get("client_id")
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidClient.New("%s", err))
+		return
+	}
+	ev.ClientID = cl.ID
+	scopes, err := GrantScopes(cl.Scopes, r.PostForm.Get("scope"))
+	if err != nil {
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidScope.New("%s", err))
+		return
+	}
+	claims := token.NewServiceClaims(cl.ID, cl.Audience, cl.AccessTTL)
+	claims.Scope = strings.Join(scopes, " ")
+	res, err := o.NewTokenResponse(claims)
+	if err != nil {
+		ev.Reason = "error in signing token"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthServerError.New("error in signing token %s", err))
+		return
+	}
+	ev.Outcome = audit.Issued
+	ev.TokenID = claims.Id
+	o.Auditor.Record(ev)
+	metrics.RecordGrant(grantType, audit.Issued)
+	WriteTokenResponse(w, r, res)
+}
+
+// Introspection is the response of the introspection endpoint
+// as defined in RFC 7662
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// IntrospectHandler tells a confidential client if a jwt or a personal
+// access token is active, an invalid token is reported as inactive
+func (o *OAuth) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := o.Clients.Authenticate(r, o.assertionAudience("/oauth/introspect")); err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthInvalidClient.New("%s", err))
+		return
+	}
+	raw := r.PostForm.Get("token")
+	if len(raw) == 0 {
+		apierror.WriteOAuthError(w, r, apierror.OAuthInvalidRequest.New("missing param %q", "token"))
+		return
+	}
+	res := &Introspection{}
+	if pat.IsToken(raw) {
+		if t, err := pat.Lookup(o.PersonalTokens, raw); err == nil {
+			res = &Introspection{
+				Active:    true,
+				Scope:     strings.Join(t.Scopes, " "),
+				TokenType: "personal_access_token",
+				Exp:       t.ExpiresAt.Unix(),
+				Iat:       t.CreatedAt.Unix(),
+				Sub:       strconv.FormatInt(t.UserID, 10),
+				Iss:       token.Issuer,
+				Jti:       t.ID,
+			}
+		}
+	} else if claims, err := o.ParseToken(raw); err == nil {
+		res = &Introspection{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			TokenType: "Bearer",
+			Exp:       claims.ExpiresAt,
+			Iat:       claims.IssuedAt,
+			Nbf:       claims.NotBefore,
+			Sub:       claims.Subject,
+			Aud:       claims.Audience,
+			Iss:       claims.Issuer,
+			Jti:       claims.Id,
+		}
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthServerError.New("%s", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(b)
+}
+
+// ParseToken verifies a token signed by the server and returns its claims
+func (o *OAuth) ParseToken(raw string) (*token.Claims, error) {
+	alg, err := token.Algorithm(o.VerifyKey)
+	if err != nil {
+		return nil, err
+	}
+	claims := &token.Claims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != alg {
+			return nil, fmt.Errorf("unexpected signing algorithm %s", t.Method.Alg())
+		}
+		return o.VerifyKey, nil
+	})
+	if err == nil && claims.Issuer != token.Issuer {
+		err = fmt.Errorf("token is not an access token")
+	}
+	// the tokens of an ended session are no longer valid
+	if err == nil && o.Users != nil {
+		err = o.Users.checkSession(claims.SessionID)
+	}
+	return claims, err
+}
+
+// TokenURL returns the url of the token endpoint
+func (o *OAuth) TokenURL(r *http.Request) string {
+	return o.BaseURL(r) + "/oauth/token"
+}
+
+// Returns the audience of the client assertions for the endpoint, it is
+// only built from the configured url as the request headers could be
+// forged. It is empty if no url is configured and no assertion is then
+// accepted.
+func (o *OAuth) assertionAudience(path string) string {
+	if len(o.IssuerURL) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(o.IssuerURL, "/") + path
+}
+
+// BaseURL returns the public url of the server, either the configured
+// one or the one the request is made to
+func (o *OAuth) BaseURL(r *http.Request) string {
+	if len(o.IssuerURL) > 0 {
+		return strings.TrimSuffix(o.IssuerURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" || r.Header.Get("X-Scheme") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+// NewTokenResponse signs the claims as an access token
+func (o *OAuth) NewTokenResponse(claims *token.Claims) (*TokenResponse, error) {
+	tkn, err := token.Sign(o.SignKey, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken: tkn,
+		TokenType:   "Bearer",
+		ExpiresIn:   claims.ExpiresAt - time.Now().Unix(),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+// Returns the error of the token endpoint for a failure of UserClaims, a
+// missing role is reported with the class while lookup failures are
+// server errors
+func userClaimsError(err error, class *apierror.OAuthClass) *apierror.OAuthError {
+	if errors.Is(err, ErrMissingRole) {
+		return class.New("%s", err)
+	}
+	return apierror.OAuthServerError.New("%s", err)
+}
+
+func (o *OAuth) grantError(w http.ResponseWriter, r *http.Request, grantType string, err *apierror.OAuthError) {
+	metrics.RecordGrant(grantType, err.Code)
+	apierror.WriteOAuthError(w, r, err)
+}
+
+// WriteTokenResponse writes the response of the token endpoint, it
+// should never be cached
+func WriteTokenResponse(w http.ResponseWriter, r *http.Request, res *TokenResponse) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		apierror.WriteOAuthError(w, r, apierror.OAuthServerError.New("%s", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Write(b)
+}
+
+// GrantScopes returns the requested scopes if all of them are allowed,
+// without any requested scope all the allowed ones are granted
+func GrantScopes(allowed []string, requested string) ([]string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, nil
+	}
+	for _, s := range scopes {
+		found := false
+		for _, a := range allowed {
+			if a == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("scope %s is not allowed", s)
+		}
+	}
+	return scopes, nil
+}
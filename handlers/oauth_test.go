@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/token"
+)
+
+func TestClientCredentialsGrant(t *testing.T) {
+	_, o, _ := newTestHandlers(t, &client.Client{
+		ID:         "stock",
+		Audience:   "stockcenter",
+		AccessTTL:  token.DefaultTTL,
+		SecretHash: secretHash("secret"),
+		Scopes:     []string{"orders:read", "orders:write"},
+	})
+	o.RegisterGrant("client_credentials", o.ClientCredentialsGrant)
+	h := http.HandlerFunc(o.TokenHandler)
+	cases := []struct {
+		name   string
+		form   url.Values
+		setup  []func(*http.Request)
+		status int
+		err    string
+		scope  string
+	}{
+		{
+			"all the scopes",
+			url.Values{"grant_type": {"client_credentials"}},
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusOK, "", "orders:read orders:write",
+		},
+		{
+			"requested scope",
+			url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}},
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusOK, "", "orders:read",
+		},
+		{
+			"secret in the form",
+			url.Values{"grant_type": {"client_credentials"}, "client_id": {"stock"}, "client_secret": {"secret"}},
+			nil,
+			http.StatusOK, "", "orders:read orders:write",
+		},
+		{
+			"wrong secret",
+			url.Values{"grant_type": {"client_credentials"}},
+			[]func(*http.Request){basicAuth("stock", "other")},
+			http.StatusUnauthorized, "invalid_client", "",
+		},
+		{
+			"scope of another client",
+			url.Values{"grant_type": {"client_credentials"}, "scope": {"curation"}},
+			[]func(*http.Request){basicAuth("stock", "secret")},
+			http.StatusBadRequest, "invalid_scope", "",
+		},
+		{
+			"unsupported grant",
+			url.Values{"grant_type": {"password"}},
+			nil,
+			http.StatusBadRequest, "unsupported_grant_type", "",
+		},
+	}
+	for _, c := range cases {
+		w := postForm(h, c.form, c.setup...)
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d got %d %s", c.name, c.status, w.Code, w.Body.String())
+			continue
+		}
+		if len(c.err) > 0 {
+			if got := oauthError(t, w); got != c.err {
+				t.Errorf("%s: expected error %s got %s", c.name, c.err, got)
+			}
+			continue
+		}
+		res := &TokenResponse{}
+		decode(t, w, res)
+		if res.Scope != c.scope {
+			t.Errorf("%s: expected scope %q got %q", c.name, c.scope, res.Scope)
+		}
+		claims, err := o.ParseToken(res.AccessToken)
+		if err != nil {
+			t.Fatalf("%s: unable to parse token %s", c.name, err)
+		}
+		if claims.Subject != token.ServicePrefix+"stock" || claims.Audience != "stockcenter" {
+			t.Errorf("%s: unexpected subject %s or audience %s", c.name, claims.Subject, claims.Audience)
+		}
+		if w.Header().Get("Cache-Control") != "no-store" || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("%s: expected an uncached json response", c.name)
+		}
+	}
+}
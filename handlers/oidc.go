@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/metrics"
+	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/oidc"
+	"github.com/dictyBase/authserver/token"
+	"github.com/go-chi/jwtauth"
+)
+
+// AuthorizationAttributes are the attributes of an authorization request
+// that are shown to the user before approval. After the decision the
+// redirect uri has the code or the error for the client.
+type AuthorizationAttributes struct {
+	ClientID    string    `json:"client_id"`
+	ClientName  string    `json:"client_name,omitempty"`
+	Scopes      []string  `json:"scopes"`
+	RedirectURI string    `json:"redirect_uri"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// AuthorizationResource is an authorization request in JSON:API format
+type AuthorizationResource struct {
+	Type       string                   `json:"type"`
+	ID         string                   `json:"id"`
+	Attributes *AuthorizationAttributes `json:"attributes"`
+}
+
+// UserInfo is the response of the userinfo endpoint
+type UserInfo struct {
+	Sub        string `json:"sub"`
+	Email      string `json:"email,omitempty"`
+	Name       string `json:"name,omitempty"`
+	GivenName  string `json:"given_name,omitempty"`
+	FamilyName string `json:"family_name,omitempty"`
+}
+
+// ProviderMetadata is the openid connect discovery document
+type ProviderMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint"`
+	DeviceAuthorizationEndpoint      string   `json:"device_authorization_endpoint,omitempty"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	GrantTypesSupported              []string `json:"grant_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	TokenEndpointAuthMethods         []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// AuthorizeHandler starts the authorization code flow, the request of the
+// client is stored and the browser is sent to the login page with its id
+func (o *OAuth) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	id := q.Get("client_id")
+	cl, ok := o.Clients.Lookup(id)
+	if len(id) == 0 || !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrUnknownClient.New("client %q is not registered", id))
+		return
+	}
+	// the redirect uri is never trusted without an exact match
+	redirectURI := q.Get("redirect_uri")
+	if len(cl.RedirectURLs) == 0 || !cl.AllowsRedirect(redirectURI) {
+		apierror.JSONAPIError(
+			w, r,
+			apierror.ErrRedirectNotAllowed.New("redirect_uri %q is not registered for client %s", redirectURI, cl.ID),
+		)
+		return
+	}
+	// rest of the errors are sent back to the client
+	state := q.Get("state")
+	if q.Get("response_type") != "code" {
+		redirectError(w, r, redirectURI, state, apierror.OAuthUnsupportedResponse.New("only the code response type is supported"))
+		return
+	}
+	if !oidc.HasScope(strings.Fields(q.Get("scope")), "openid") {
+		redirectError(w, r, redirectURI, state, apierror.OAuthInvalidScope.New("openid scope is required"))
+		return
+	}
+	scopes, err := GrantScopes(append(append([]string{}, oidc.Scopes...), cl.Scopes...), q.Get("scope"))
+	if err != nil {
+		redirectError(w, r, redirectURI, state, apierror.OAuthInvalidScope.New("%s", err))
+		return
+	}
+	challenge := q.Get("code_challenge")
+	switch {
+	case len(challenge) == 0 && !cl.IsConfidential():
+		redirectError(w, r, redirectURI, state, apierror.OAuthInvalidRequest.New("code_challenge is required for public clients"))
+		return
+	case len(challenge) > 0 && q.Get("code_challenge_method") != "S256":
+		redirectError(w, r, redirectURI, state, apierror.OAuthInvalidRequest.New("code_challenge_method should be S256"))
+		return
+	}
+	req, err := o.Authorizations.Create(&oidc.Request{
+		ClientID:      cl.ID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		State:         state,
+		Nonce:         q.Get("nonce"),
+		CodeChallenge: challenge,
+	})
+	if err != nil {
+		redirectError(w, r, redirectURI, state, apierror.OAuthServerError.New("unable to store request %s", err))
+		return
+	}
+	http.Redirect(w, r, appendQuery(o.LoginURI, url.Values{"request_id": {req.ID}}), http.StatusFound)
+}
+
+// ConsentInfoHandler returns the pending authorization request, for
+// showing the client and the scopes to the user before approval
+func (o *OAuth) ConsentInfoHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := o.Authorizations.Get(r.URL.Query().Get("request_id"))
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrAuthorizationNotFound.New("%s", err))
+		return
+	}
+	writeJSONAPI(w, r, http.StatusOK, o.authorizationResource(req, req.RedirectURI))
+}
+
+type consentDecision struct {
+	Data struct {
+		Attributes struct {
+			RequestID string `json:"request_id"`
+			Approve   bool   `json:"approve"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// ConsentDecisionHandler records the approval or denial of the request by
+// the logged in user, the frontend sends the browser to the redirect uri
+// of the response
+func (o *OAuth) ConsentDecisionHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	dec := &consentDecision{}
+	if err := json.NewDecoder(r.Body).Decode(dec); err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidParam.New("unable to decode request body %s", err))
+		return
+	}
+	req, err := o.Authorizations.Decide(dec.Data.Attributes.RequestID, uid, dec.Data.Attributes.Approve)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrAuthorizationNotFound.New("%s", err))
+		return
+	}
+	ev := audit.NewEvent(r, audit.ActionAuthorizationCode, audit.Denied)
+	ev.UserID = uid
+	ev.ClientID = req.ClientID
+	ev.Reason = "denied by user"
+	params := url.Values{"error": {apierror.OAuthAccessDenied.Code}}
+	if len(req.Code) > 0 {
+		ev.Outcome = audit.Validated
+		ev.Reason = "approved by user"
+		params = url.Values{"code": {req.Code}}
+	}
+	o.Auditor.Record(ev)
+	if len(req.State) > 0 {
+		params.Set("state", req.State)
+	}
+	writeJSONAPI(w, r, http.StatusOK, o.authorizationResource(req, appendQuery(req.RedirectURI, params)))
+}
+
+// AuthorizationCodeGrant exchanges the code of an approved request for
+// the access, id and refresh tokens
+func (o *OAuth) AuthorizationCodeGrant(w http.ResponseWriter, r *http.Request) {
+	grantType := "authorization_code"
+	ev := audit.NewEvent(r, audit.ActionAuthorizationCode, audit.Denied)
+	cl, err := o.tokenClient(r)
+	if err != nil {
+		ev.ClientID = r.PostForm.Get("client_id")
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidClient.New("%s", err))
+		return
+	}
+	ev.ClientID = cl.ID
+	req, err := o.Authorizations.Redeem(
+		r.PostForm.Get("code"),
+		cl.ID,
+		r.PostForm.Get("redirect_uri"),
+		r.PostForm.Get("code_verifier"),
+	)
+	if err != nil {
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidGrant.New("%s", err))
+		return
+	}
+	ev.UserID = req.UserID
+	g := &oidc.Grant{
+		ClientID: cl.ID,
+		UserID:   req.UserID,
+		Scopes:   req.Scopes,
+		AuthTime: req.AuthTime,
+	}
+	o.issueUserTokens(w, r, grantType, ev, cl, g, req.Scopes, req.Nonce)
+}
+
+// RefreshTokenGrant issues new tokens for a refresh token, the refresh
+// token is replaced by a new one. A narrower scope could be requested for
+// the access token.
+func (o *OAuth) RefreshTokenGrant(w http.ResponseWriter, r *http.Request) {
+	grantType := "refresh_token"
+	ev := audit.NewEvent(r, audit.ActionRefreshToken, audit.Denied)
+	cl, err := o.tokenClient(r)
+	if err != nil {
+		ev.ClientID = r.PostForm.Get("client_id")
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidClient.New("%s", err))
+		return
+	}
+	ev.ClientID = cl.ID
+	g, err := o.RefreshTokens.Use(r.PostForm.Get("refresh_token"), cl.ID)
+	if err != nil {
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidGrant.New("%s", err))
+		return
+	}
+	ev.UserID = g.UserID
+	// the refresh tokens are no longer valid once the session is ended
+	if err := o.Users.checkSession(g.SessionID); err != nil {
+		ev.SessionID = g.SessionID
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidGrant.New("%s", err))
+		return
+	}
+	scopes, err := GrantScopes(g.Scopes, r.PostForm.Get("scope"))
+	if err != nil {
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthInvalidScope.New("%s", err))
+		return
+	}
+	o.issueUserTokens(w, r, grantType, ev, cl, g, scopes, "")
+}
+
+// Signs the access token with the scopes, the id token if openid is
+// granted and a new refresh token if offline_access is granted and the
+// client has a refresh lifetime. The code starts a session that lasts as
+// long as the refresh tokens, rotating them does not extend it.
+func (o *OAuth) issueUserTokens(w http.ResponseWriter, r *http.Request, grantType string, ev *audit.Event, cl *client.Client, g *oidc.Grant, scopes []string, nonce string) {
+	claims, err := o.Users.UserClaims(r.Context(), cl, g.UserID)
+	if err != nil {
+		ev.Reason = err.Error()
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, userClaimsError(err, apierror.OAuthInvalidGrant))
+		return
+	}
+	claims.Scope = strings.Join(scopes, " ")
+	offline := cl.RefreshTTL > 0 && oidc.HasScope(g.Scopes, "offline_access")
+	if len(g.SessionID) == 0 {
+		g.ExpiresAt = time.Unix(claims.ExpiresAt, 0)
+		if offline {
+			g.ExpiresAt = time.Now().Add(cl.RefreshTTL)
+		}
+		if err := o.Users.startSession(claims, g.UserID, "oidc", cl.ID, ev, g.ExpiresAt); err != nil {
+			ev.Reason = "error in storing session"
+			o.Auditor.Record(ev)
+			o.grantError(w, r, grantType, apierror.OAuthServerError.New("unable to store session %s", err))
+			return
+		}
+		g.SessionID = claims.SessionID
+	} else {
+		claims.SessionID = g.SessionID
+		ev.SessionID = g.SessionID
+	}
+	// no token outlives its session
+	if claims.ExpiresAt > g.ExpiresAt.Unix() {
+		claims.ExpiresAt = g.ExpiresAt.Unix()
+	}
+	res, err := o.NewTokenResponse(claims)
+	if err == nil && oidc.HasScope(scopes, "openid") {
+		idClaims := token.NewIDClaims(g.UserID, o.BaseURL(r), cl.ID, g.AuthTime, o.IDTokenTTL)
+		idClaims.Nonce = nonce
+		res.IDToken, err = token.Sign(o.SignKey, idClaims)
+	}
+	if err != nil {
+		ev.Reason = "error in signing token"
+		o.Auditor.Record(ev)
+		o.grantError(w, r, grantType, apierror.OAuthServerError.New("error in signing token %s", err))
+		return
+	}
+	if offline {
+		next := *g
+		res.RefreshToken, err = o.RefreshTokens.Issue(&next)
+		if err != nil {
+			ev.Reason = "error in storing refresh token"
+			o.Auditor.Record(ev)
+			o.grantError(w, r, grantType, apierror.OAuthServerError.New("unable to store refresh token %s", err))
+			return
+		}
+	}
+	ev.Outcome = audit.Issued
+	ev.TokenID = claims.Id
+	o.Auditor.Record(ev)
+	metrics.RecordGrant(grantType, audit.Issued)
+	WriteTokenResponse(w, r, res)
+}
+
+// UserInfoHandler returns the user of the access token from the user
+// service, the email and the names are given only for the email and
+// profile scopes if the token has any scope
+func (o *OAuth) UserInfoHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("%s", err))
+		return
+	}
+	u, aerr := o.Users.LookupUser(r.Context(), uid)
+	if aerr != nil {
+		apierror.JSONAPIError(w, r, aerr)
+		return
+	}
+	scope, _ := claims["scope"].(string)
+	scopes := strings.Fields(scope)
+	attr := u.Data.Attributes
+	info := &UserInfo{Sub: strconv.FormatInt(uid, 10)}
+	if len(scopes) == 0 || oidc.HasScope(scopes, "email") {
+		info.Email = attr.Email
+	}
+	if len(scopes) == 0 || oidc.HasScope(scopes, "profile") {
+		info.GivenName = attr.FirstName
+		info.FamilyName = attr.LastName
+		info.Name = strings.TrimSpace(attr.FirstName + " " + attr.LastName)
+	}
+	writeJSON(w, r, info)
+}
+
+// DiscoveryHandler returns the openid connect discovery document
+func (o *OAuth) DiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	base := o.BaseURL(r)
+	alg, err := token.Algorithm(o.VerifyKey)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrTokenSigning.New("%s", err))
+		return
+	}
+	var grants []string
+	for g := range o.grants {
+		grants = append(grants, g)
+	}
+	sort.Strings(grants)
+	meta := &ProviderMetadata{
+		Issuer:                           base,
+		AuthorizationEndpoint:            base + "/oauth/authorize",
+		TokenEndpoint:                    o.TokenURL(r),
+		UserinfoEndpoint:                 base + "/userinfo",
+		JWKSURI:                          base + "/.well-known/jwks.json",
+		IntrospectionEndpoint:            base + "/oauth/introspect",
+		ResponseTypesSupported:           []string{"code"},
+		GrantTypesSupported:              grants,
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{alg},
+		ScopesSupported:                  oidc.Scopes,
+		TokenEndpointAuthMethods:         []string{"client_secret_basic", "client_secret_post", "private_key_jwt", "none"},
+		CodeChallengeMethodsSupported:    []string{"S256"},
+		ClaimsSupported:                  []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "name", "given_name", "family_name"},
+	}
+	if o.Devices != nil {
+		meta.DeviceAuthorizationEndpoint = base + "/oauth/device/code"
+	}
+	writeJSON(w, r, meta)
+}
+
+// JWKSHandler returns the public key of the server as a json web key set
+func (o *OAuth) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	k, err := token.NewJWK(o.VerifyKey)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrTokenSigning.New("%s", err))
+		return
+	}
+	writeJSON(w, r, &token.JWKS{Keys: []*token.JWK{k}})
+}
+
+// Returns the client of a token request, a public client only gives its
+// id while a confidential one has to authenticate
+func (o *OAuth) tokenClient(r *http.Request) (*client.Client, error) {
+	id := r.PostForm.Get("client_id")
+	_, _, basic := r.BasicAuth()
+	if !basic && len(id) > 0 && len(r.PostForm.Get("client_secret")) == 0 && len(r.PostForm.Get("client_assertion")) == 0 {
+		if cl, ok := o.Clients.Lookup(id); ok && !cl.IsConfidential() {
+			return cl, nil
+		}
+	}
+	return o.Clients.Authenticate(r, o.assertionAudience("/oauth/token"))
+}
+
+func (o *OAuth) authorizationResource(req *oidc.Request, redirectURI string) *AuthorizationResource {
+	attr := &AuthorizationAttributes{
+		ClientID:    req.ClientID,
+		Scopes:      req.Scopes,
+		RedirectURI: redirectURI,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if cl, ok := o.Clients.Lookup(req.ClientID); ok {
+		attr.ClientName = cl.Name
+	}
+	return &AuthorizationResource{Type: "authorization_requests", ID: req.ID, Attributes: attr}
+}
+
+// Sends the error back to the client through its redirect uri
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI, state string, err *apierror.OAuthError) {
+	params := url.Values{"error": {err.Code}, "error_description": {err.Description}}
+	if len(state) > 0 {
+		params.Set("state", state)
+	}
+	http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+}
+
+// Adds the parameters to the query of the url
+func appendQuery(u string, params url.Values) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + params.Encode()
+	}
+	return u + "?" + params.Encode()
+}
+
+// Writes the value as a plain json document
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrJSONEncoding.New("%s", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/dictyBase/authserver/client"
+	"github.com/dictyBase/authserver/oidc"
+	"github.com/dictyBase/authserver/token"
+)
+
+// the pkce example of RFC 7636
+const (
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	testRedirect  = "https://web.dictybase.org/callback"
+)
+
+func newOIDCHandlers(t *testing.T) (*Jwt, *OAuth) {
+	j, o, fr := newTestHandlers(t, &client.Client{
+		ID:           "web",
+		Name:         "Web",
+		Audience:     token.DefaultAudience,
+		AccessTTL:    time.Hour,
+		RefreshTTL:   24 * time.Hour,
+		RedirectURLs: []string{testRedirect},
+	})
+	fr.addUser(42, "ada@dictybase.org")
+	o.Authorizations = oidc.NewStore(time.Minute, time.Minute)
+	o.RefreshTokens = oidc.NewRefreshStore()
+	o.LoginURI = "https://dictybase.org/oauth/login"
+	o.IDTokenTTL = time.Hour
+	o.RegisterGrant("authorization_code", o.AuthorizationCodeGrant)
+	o.RegisterGrant("refresh_token", o.RefreshTokenGrant)
+	return j, o
+}
+
+func authorizeQuery(scope string) url.Values {
+	return url.Values{
+		"client_id":             {"web"},
+		"redirect_uri":          {testRedirect},
+		"response_type":         {"code"},
+		"scope":                 {scope},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {testChallenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// Returns the location of the redirect of the authorize endpoint
+func authorize(t *testing.T, o *OAuth, q url.Values) *url.URL {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/oauth/authorize?"+q.Encode(), nil)
+	w := httptest.NewRecorder()
+	o.AuthorizeHandler(w, r)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected a redirect got %d %s", w.Code, w.Body.String())
+	}
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// Runs the authorization request with the scope through the approval of
+// user 42 and returns the code for the client
+func authorizeCode(t *testing.T, j *Jwt, o *OAuth, scope string) string {
+	t.Helper()
+	login := authorize(t, o, authorizeQuery(scope))
+	id := login.Query().Get("request_id")
+	body := `{"data": {"attributes": {"request_id": "` + id + `", "approve": true}}}`
+	r := httptest.NewRequest("POST", "/oauth/authorize/consent", strings.NewReader(body))
+	w := serveWithToken(t, j, http.HandlerFunc(o.ConsentDecisionHandler), r, token.NewClaims(42, token.DefaultAudience, time.Hour))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the consent got %d %s", w.Code, w.Body.String())
+	}
+	res := struct {
+		Data *AuthorizationResource `json:"data"`
+	}{}
+	decode(t, w, &res)
+	u, err := url.Parse(res.Data.Attributes.RedirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("state") != "xyz" {
+		t.Errorf("expected the state in the redirect uri got %s", u)
+	}
+	return u.Query().Get("code")
+}
+
+func redeem(o *OAuth, code string) *httptest.ResponseRecorder {
+	return postForm(http.HandlerFunc(o.TokenHandler), url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"web"},
+		"code":          {code},
+		"redirect_uri":  {testRedirect},
+		"code_verifier": {testVerifier},
+	})
+}
+
+func refresh(o *OAuth, raw string) *httptest.ResponseRecorder {
+	return postForm(http.HandlerFunc(o.TokenHandler), url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"web"},
+		"refresh_token": {raw},
+	})
+}
+
+func TestAuthorizeHandler(t *testing.T) {
+	_, o := newOIDCHandlers(t)
+	login := authorize(t, o, authorizeQuery("openid email"))
+	if !strings.HasPrefix(login.String(), o.LoginURI+"?request_id=") {
+		t.Errorf("expected a redirect to the login page got %s", login)
+	}
+	r := httptest.NewRequest("GET", "/oauth/authorize/consent?request_id="+login.Query().Get("request_id"), nil)
+	w := httptest.NewRecorder()
+	o.ConsentInfoHandler(w, r)
+	info := struct {
+		Data *AuthorizationResource `json:"data"`
+	}{}
+	decode(t, w, &info)
+	if info.Data == nil || info.Data.Attributes.ClientName != "Web" || len(info.Data.Attributes.Scopes) != 2 {
+		t.Errorf("expected the request of the client got %s", w.Body.String())
+	}
+
+	cases := []struct {
+		name  string
+		param string
+		value string
+		err   string
+	}{
+		{"no openid scope", "scope", "email", "invalid_scope"},
+		{"unknown scope", "scope", "openid admin", "invalid_scope"},
+		{"token response", "response_type", "token", "unsupported_response_type"},
+		{"public client without pkce", "code_challenge", "", "invalid_request"},
+		{"plain pkce", "code_challenge_method", "plain", "invalid_request"},
+	}
+	for _, c := range cases {
+		q := authorizeQuery("openid")
+		q.Set(c.param, c.value)
+		u := authorize(t, o, q)
+		if !strings.HasPrefix(u.String(), testRedirect) || u.Query().Get("error") != c.err {
+			t.Errorf("%s: expected error %s in the redirect got %s", c.name, c.err, u)
+		}
+	}
+	q := authorizeQuery("openid")
+	q.Set("redirect_uri", "https://evil.example.org/callback")
+	r = httptest.NewRequest("GET", "/oauth/authorize?"+q.Encode(), nil)
+	w = httptest.NewRecorder()
+	o.AuthorizeHandler(w, r)
+	if w.Code == http.StatusFound {
+		t.Errorf("expected no redirect to an unregistered url got %s", w.Header().Get("Location"))
+	}
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+	j, o := newOIDCHandlers(t)
+	code := authorizeCode(t, j, o, "openid email offline_access")
+	if w := postForm(http.HandlerFunc(o.TokenHandler), url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"web"},
+		"code":          {code},
+		"redirect_uri":  {testRedirect},
+		"code_verifier": {"wrong-verifier-wrong-verifier-wrong-verifier"},
+	}); oauthError(t, w) != "invalid_grant" {
+		t.Errorf("expected invalid_grant for a wrong verifier got %s", w.Body.String())
+	}
+
+	code = authorizeCode(t, j, o, "openid email offline_access")
+	w := redeem(o, code)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the code got %d %s", w.Code, w.Body.String())
+	}
+	res := &TokenResponse{}
+	decode(t, w, res)
+	if len(res.IDToken) == 0 || len(res.RefreshToken) == 0 {
+		t.Fatalf("expected an id and a refresh token got %s", w.Body.String())
+	}
+	idClaims := &token.IDClaims{}
+	_, err := jwt.ParseWithClaims(res.IDToken, idClaims, func(*jwt.Token) (interface{}, error) {
+		return o.VerifyKey, nil
+	})
+	if err != nil {
+		t.Fatalf("unable to parse id token %s", err)
+	}
+	if idClaims.Issuer != o.IssuerURL || idClaims.Audience != "web" || idClaims.Nonce != "n-0S6_WzA2Mj" {
+		t.Errorf("unexpected id token claims %+v", idClaims)
+	}
+	if w := redeem(o, code); oauthError(t, w) != "invalid_grant" {
+		t.Errorf("expected invalid_grant for a used code got %s", w.Body.String())
+	}
+
+	w = refresh(o, res.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the refresh got %d %s", w.Code, w.Body.String())
+	}
+	next := &TokenResponse{}
+	decode(t, w, next)
+	if len(next.RefreshToken) == 0 || next.RefreshToken == res.RefreshToken {
+		t.Errorf("expected a new refresh token got %s", w.Body.String())
+	}
+	if w := refresh(o, res.RefreshToken); oauthError(t, w) != "invalid_grant" {
+		t.Errorf("expected invalid_grant for a used refresh token got %s", w.Body.String())
+	}
+}
+
+func TestNoRefreshWithoutOfflineAccess(t *testing.T) {
+	j, o := newOIDCHandlers(t)
+	w := redeem(o, authorizeCode(t, j, o, "openid email"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the code got %d %s", w.Code, w.Body.String())
+	}
+	res := &TokenResponse{}
+	decode(t, w, res)
+	if len(res.RefreshToken) > 0 {
+		t.Error("expected no refresh token without offline_access")
+	}
+}
+
+func TestUserInfoHandler(t *testing.T) {
+	j, o := newOIDCHandlers(t)
+	cases := []struct {
+		name     string
+		scope    string
+		email    string
+		fullName string
+	}{
+		{"email scope", "openid email", "ada@dictybase.org", ""},
+		{"profile scope", "openid profile", "", "Ada Lovelace"},
+		{"no scope", "", "ada@dictybase.org", "Ada Lovelace"},
+	}
+	for _, c := range cases {
+		claims := token.NewClaims(42, token.DefaultAudience, time.Hour)
+		claims.Scope = c.scope
+		r := httptest.NewRequest("GET", "/userinfo", nil)
+		w := serveWithToken(t, j, http.HandlerFunc(o.UserInfoHandler), r, claims)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200 got %d %s", c.name, w.Code, w.Body.String())
+			continue
+		}
+		info := &UserInfo{}
+		decode(t, w, info)
+		if info.Sub != "42" || info.Email != c.email || info.Name != c.fullName {
+			t.Errorf("%s: unexpected user info %+v", c.name, info)
+		}
+	}
+	r := httptest.NewRequest("GET", "/userinfo", nil)
+	if w := serveWithToken(t, j, http.HandlerFunc(o.UserInfoHandler), r, token.NewClaims(7, token.DefaultAudience, time.Hour)); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for an unknown user got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/metrics"
+	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/pat"
+	"github.com/dictyBase/authserver/resource"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+)
+
+// PersonalTokens manages the personal access tokens of the users
+type PersonalTokens struct {
+	Store   pat.Store
+	Auditor *audit.Auditor
+	// Scopes that could be given to a token, a token without any
+	// scope has all the access of its user except for the resources
+	// that require scopes
+	Scopes     []string
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+	// Resources whose required scopes are checked by /authorize
+	Resources *resource.Registry
+}
+
+// PATAttributes are the attributes of a personal access token resource,
+// the token is only given when it is created
+type PATAttributes struct {
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PATResource is a personal access token in JSON:API format
+type PATResource struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id"`
+	Attributes *PATAttributes `json:"attributes"`
+}
+
+type patRequest struct {
+	Data struct {
+		Attributes struct {
+			Name      string     `json:"name"`
+			Scopes    []string   `json:"scopes"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// CreateHandler creates a personal access token for the user, the token
+// is returned only in this response. A bearer token with scopes could
+// only create a token with some of its scopes, they are all given if none
+// is asked for.
+func (p *PersonalTokens) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	req := &patRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidParam.New("unable to decode request body %s", err))
+		return
+	}
+	attr := req.Data.Attributes
+	if len(strings.TrimSpace(attr.Name)) == 0 {
+		apierror.JSONAPIError(w, r, apierror.ErrMissingParam.New("missing attribute %q", "name"))
+		return
+	}
+	allowed := p.Scopes
+	if scopes := tokenScopes(r); len(scopes) > 0 {
+		allowed = intersect(p.Scopes, scopes)
+		if len(attr.Scopes) == 0 {
+			attr.Scopes = allowed
+		}
+		if len(attr.Scopes) == 0 {
+			apierror.JSONAPIError(
+				w, r,
+				apierror.ErrInvalidParam.New("bearer token has none of the scopes of the personal access tokens"),
+			)
+			return
+		}
+	}
+	if _, err := GrantScopes(allowed, strings.Join(attr.Scopes, " ")); err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrInvalidParam.New("%s", err))
+		return
+	}
+	ttl := p.DefaultTTL
+	if attr.ExpiresAt != nil {
+		ttl = time.Until(*attr.ExpiresAt)
+		if ttl <= 0 || ttl > p.MaxTTL {
+			apierror.JSONAPIError(
+				w, r,
+				apierror.ErrInvalidParam.New("expires_at should be in the future and within %s", p.MaxTTL),
+			)
+			return
+		}
+	}
+	raw, t, err := pat.New(uid, attr.Name, attr.Scopes, ttl)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrTokenSigning.New("%s", err))
+		return
+	}
+	if err := p.Store.Create(t); err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrStore.New("unable to store token %s", err))
+		return
+	}
+	ev := audit.NewEvent(r, audit.ActionPersonalToken, audit.Issued)
+	ev.UserID = uid
+	ev.TokenID = t.ID
+	p.Auditor.Record(ev)
+	res := patResource(t)
+	res.Attributes.Token = raw
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSONAPI(w, r, http.StatusCreated, res)
+}
+
+// ListHandler lists the personal access tokens of the user
+func (p *PersonalTokens) ListHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	tokens, err := p.Store.List(uid)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrStore.New("unable to list tokens %s", err))
+		return
+	}
+	data := make([]*PATResource, 0, len(tokens))
+	for _, t := range tokens {
+		data = append(data, patResource(t))
+	}
+	writeJSONAPI(w, r, http.StatusOK, data)
+}
+
+// RevokeHandler deletes a personal access token of the user
+func (p *PersonalTokens) RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := p.Store.Delete(uid, id); err != nil {
+		if errors.Is(err, pat.ErrNotFound) {
+			apierror.JSONAPIError(w, r, apierror.ErrTokenNotFound.New("no token with id %s", id))
+			return
+		}
+		apierror.JSONAPIError(w, r, apierror.ErrStore.New("unable to delete token %s", err))
+		return
+	}
+	ev := audit.NewEvent(r, audit.ActionPersonalToken, audit.Revoked)
+	ev.UserID = uid
+	ev.TokenID = id
+	p.Auditor.Record(ev)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuthorizeMiddleware validates the personal access tokens given to
+// /authorize, any other token is passed on for validation as a jwt
+func (p *PersonalTokens) AuthorizeMiddleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		raw := bearerToken(r)
+		if !pat.IsToken(raw) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ev := audit.NewEvent(r, audit.ActionAuthorize, audit.Denied)
+		t, err := pat.Lookup(p.Store, raw)
+		if err != nil {
+			metrics.RecordAuthorize(metrics.AuthorizeDenied)
+			ev.Reason = err.Error()
+			if t != nil {
+				ev.UserID = t.UserID
+				ev.TokenID = t.ID
+			}
+			p.Auditor.Record(ev)
+			apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("%s", err))
+			return
+		}
+		ev.UserID = t.UserID
+		ev.TokenID = t.ID
+		// personal access tokens have no audience, only their
+		// scopes are checked
+		if res, ok := p.Resources.Match(r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Original-Uri")); ok {
+			if err := res.CheckScopes(t.Scopes); err != nil {
+				metrics.RecordAuthorize(metrics.AuthorizeDenied)
+				ev.Reason = err.Error()
+				p.Auditor.Record(ev)
+				apierror.JSONAPIError(w, r, resourceError(err))
+				return
+			}
+		}
+		metrics.RecordAuthorize(metrics.AuthorizeAllowed)
+		ev.Outcome = audit.Validated
+		p.Auditor.Record(ev)
+		middlewares.AddLogField(r.Context(), "user_id", t.UserID)
+		fmt.Fprintf(w, "token is %s", "valid")
+	}
+	return http.HandlerFunc(fn)
+}
+
+func patResource(t *pat.Token) *PATResource {
+	return &PATResource{
+		Type: "personal_tokens",
+		ID:   t.ID,
+		Attributes: &PATAttributes{
+			Name:      t.Name,
+			Scopes:    t.Scopes,
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: t.ExpiresAt,
+		},
+	}
+}
+
+// Returns the scopes of the bearer token
+func tokenScopes(r *http.Request) []string {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return nil
+	}
+	scope, _ := claims["scope"].(string)
+	return strings.Fields(scope)
+}
+
+// Returns the token of the bearer authorization header
+func bearerToken(r *http.Request) string {
+	hdr := r.Header.Get("Authorization")
+	if len(hdr) > 7 && strings.EqualFold(hdr[0:7], "bearer ") {
+		return strings.TrimSpace(hdr[7:])
+	}
+	return ""
+}
+
+// Writes the data as a JSON:API document
+func writeJSONAPI(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	writeDocument(w, r, status, map[string]interface{}{"data": data})
+}
+
+// Writes a complete JSON:API document
+func writeDocument(w http.ResponseWriter, r *http.Request, status int, doc interface{}) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrJSONEncoding.New("%s", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
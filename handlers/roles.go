@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
+
+	"github.com/dictyBase/authserver/message"
+)
+
+// Roles fetches the roles of the user and the permissions of those roles
+// from the permission service, the permissions of the roles are fetched
+// concurrently. A permission of a resource is given as
+// resource:permission, both the lists are sorted without duplicates.
+func (j *Jwt) Roles(ctx context.Context, uid int64) ([]string, []string, error) {
+	rc, err := j.Request.RoleRequestWithContext(
+		ctx,
+		j.Topics[message.RoleList],
+		&pubsub.IdRequest{Id: uid},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	roles := make(map[string]bool)
+	replies := make([]*user.PermissionCollection, len(rc.Data))
+	errs := make([]error, len(rc.Data))
+	var wg sync.WaitGroup
+	for i, rd := range rc.Data {
+		if rd.Attributes == nil {
+			continue
+		}
+		roles[rd.Attributes.Role] = true
+		wg.Add(1)
+		go func(i int, id int64) {
+			defer wg.Done()
+			replies[i], errs[i] = j.Request.PermissionRequestWithContext(
+				ctx,
+				j.Topics[message.PermissionList],
+				&pubsub.IdRequest{Id: id},
+			)
+		}(i, rd.Id)
+	}
+	wg.Wait()
+	perms := make(map[string]bool)
+	for i, pc := range replies {
+		if errs[i] != nil {
+			return nil, nil, errs[i]
+		}
+		if pc == nil {
+			continue
+		}
+		for _, pd := range pc.Data {
+			if pd.Attributes == nil {
+				continue
+			}
+			p := pd.Attributes.Permission
+			if len(pd.Attributes.Resource) > 0 {
+				p = pd.Attributes.Resource + ":" + p
+			}
+			perms[p] = true
+		}
+	}
+	return sortedKeys(roles), sortedKeys(perms), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/middlewares"
+	"github.com/dictyBase/authserver/session"
+	"github.com/dictyBase/authserver/token"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+)
+
+// the last seen time of a session is updated at most once in this interval
+const touchInterval = time.Minute
+
+// SessionAttributes are the attributes of a session resource, current
+// tells the session of the bearer token
+type SessionAttributes struct {
+	Provider   string    `json:"provider"`
+	ClientID   string    `json:"client_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SessionResource is a login session in JSON:API format
+type SessionResource struct {
+	Type       string             `json:"type"`
+	ID         string             `json:"id"`
+	Attributes *SessionAttributes `json:"attributes"`
+}
+
+// ListSessionsHandler lists the active sessions of the user
+func (j *Jwt) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	sessions, err := j.Sessions.List(uid)
+	if err != nil {
+		apierror.JSONAPIError(w, r, apierror.ErrStore.New("unable to list sessions %s", err))
+		return
+	}
+	current := tokenSession(r)
+	data := make([]*SessionResource, 0, len(sessions))
+	for _, s := range sessions {
+		data = append(data, &SessionResource{
+			Type: "sessions",
+			ID:   s.ID,
+			Attributes: &SessionAttributes{
+				Provider:   s.Provider,
+				ClientID:   s.ClientID,
+				IP:         s.IP,
+				UserAgent:  s.UserAgent,
+				Current:    s.ID == current,
+				CreatedAt:  s.CreatedAt,
+				LastSeenAt: s.LastSeenAt,
+				ExpiresAt:  s.ExpiresAt,
+			},
+		})
+	}
+	writeJSONAPI(w, r, http.StatusOK, data)
+}
+
+// EndSessionHandler ends a session of the user, its tokens are rejected
+// from then on
+func (j *Jwt) EndSessionHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok {
+		apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("unable to retrieve %s from context", "user id"))
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := j.Sessions.End(uid, id, time.Now()); err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			apierror.JSONAPIError(w, r, apierror.ErrSessionNotFound.New("no active session with id %s", id))
+			return
+		}
+		apierror.JSONAPIError(w, r, apierror.ErrStore.New("unable to end session %s", err))
+		return
+	}
+	ev := audit.NewEvent(r, audit.ActionSession, audit.Revoked)
+	ev.UserID = uid
+	ev.SessionID = id
+	j.Auditor.Record(ev)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SessionMiddleware rejects the tokens of the ended sessions, it should
+// come after UserAuthenticator
+func (j *Jwt) SessionMiddleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if err := j.checkSession(tokenSession(r)); err != nil {
+			apierror.JSONAPIError(w, r, sessionError(err))
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Returns an error for the token of an ended session, the last seen
+// time of an active session is updated on the way. The tokens without
+// a session are not checked.
+func (j *Jwt) checkSession(sid string) error {
+	if len(sid) == 0 {
+		return nil
+	}
+	s, err := session.Check(j.Sessions, sid)
+	if errors.Is(err, session.ErrNotFound) && j.AcceptUnknownSessions {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if now.Sub(s.LastSeenAt) > touchInterval {
+		if err := j.Sessions.Touch(sid, now); err != nil {
+			log.Printf("unable to update last seen time of session %s %s\n", sid, err)
+		}
+	}
+	return nil
+}
+
+// Returns the session id of the bearer token
+func tokenSession(r *http.Request) string {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return ""
+	}
+	sid, _ := claims["sid"].(string)
+	return sid
+}
+
+// Starts a session of the user for the token of the claims that lasts
+// until the given time, the claims and the audit event refer to it
+func (j *Jwt) startSession(claims *token.Claims, uid int64, provider, clientID string, ev *audit.Event, expiresAt time.Time) error {
+	sess := session.New(uid, provider, clientID, ev.IP, ev.UserAgent, expiresAt)
+	if err := j.Sessions.Create(sess); err != nil {
+		return err
+	}
+	claims.SessionID = sess.ID
+	ev.SessionID = sess.ID
+	return nil
+}
+
+// Returns the error of a token whose session could not be checked
+func sessionError(err error) *apierror.Error {
+	if errors.Is(err, session.ErrEnded) || errors.Is(err, session.ErrNotFound) {
+		return apierror.ErrInvalidToken.New("%s", err)
+	}
+	return apierror.ErrStore.New("unable to check session %s", err)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/authserver/session"
+	"github.com/dictyBase/authserver/token"
+	"github.com/go-chi/chi"
+)
+
+// Returns the claims of a token of the user in a new session
+func sessionClaims(t *testing.T, j *Jwt, uid int64) *token.Claims {
+	t.Helper()
+	sess := session.New(uid, "google", "web", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err := j.Sessions.Create(sess); err != nil {
+		t.Fatal(err)
+	}
+	claims := token.NewClaims(uid, token.DefaultAudience, time.Hour)
+	claims.SessionID = sess.ID
+	return claims
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	j, _, _ := newTestHandlers(t)
+	ok := j.SessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	active := sessionClaims(t, j, 42)
+	ended := sessionClaims(t, j, 42)
+	if err := j.Sessions.End(42, ended.SessionID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	unknown := token.NewClaims(42, token.DefaultAudience, time.Hour)
+	unknown.SessionID = "unknown"
+	cases := []struct {
+		name   string
+		claims *token.Claims
+		accept bool
+		status int
+	}{
+		{"no session", token.NewClaims(42, token.DefaultAudience, time.Hour), false, http.StatusOK},
+		{"active session", active, false, http.StatusOK},
+		{"ended session", ended, false, http.StatusUnauthorized},
+		{"ended session with unknown accepted", ended, true, http.StatusUnauthorized},
+		{"unknown session", unknown, false, http.StatusUnauthorized},
+		{"unknown session accepted", unknown, true, http.StatusOK},
+	}
+	for _, c := range cases {
+		j.AcceptUnknownSessions = c.accept
+		r := httptest.NewRequest("GET", "/users/me", nil)
+		if w := serveWithToken(t, j, ok, r, c.claims); w.Code != c.status {
+			t.Errorf("%s: expected status %d got %d %s", c.name, c.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSessionHandlers(t *testing.T) {
+	j, _, _ := newTestHandlers(t)
+	router := chi.NewRouter()
+	router.Get("/sessions", j.ListSessionsHandler)
+	router.Delete("/sessions/{id}", j.EndSessionHandler)
+	current := sessionClaims(t, j, 42)
+	other := sessionClaims(t, j, 42)
+	foreign := sessionClaims(t, j, 7)
+
+	r := httptest.NewRequest("GET", "/sessions", nil)
+	w := serveWithToken(t, j, router, r, current)
+	res := struct {
+		Data []*SessionResource `json:"data"`
+	}{}
+	decode(t, w, &res)
+	if len(res.Data) != 2 {
+		t.Fatalf("expected the two sessions of the user got %s", w.Body.String())
+	}
+	for _, s := range res.Data {
+		if s.Attributes.Current != (s.ID == current.SessionID) {
+			t.Errorf("expected only session %s to be current got %+v", current.SessionID, s)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"session of another user", foreign.SessionID, http.StatusNotFound},
+		{"unknown session", "unknown", http.StatusNotFound},
+		{"end session", other.SessionID, http.StatusNoContent},
+		{"ended session", other.SessionID, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("DELETE", "/sessions/"+c.id, nil)
+		if w := serveWithToken(t, j, router, r, current); w.Code != c.status {
+			t.Errorf("%s: expected status %d got %d %s", c.name, c.status, w.Code, w.Body.String())
+		}
+	}
+	if _, err := session.Check(j.Sessions, foreign.SessionID); err != nil {
+		t.Errorf("expected the session of another user to stay active got %s", err)
+	}
+}
+
+func TestRefreshAfterEndSession(t *testing.T) {
+	j, o := newOIDCHandlers(t)
+	w := redeem(o, authorizeCode(t, j, o, "openid offline_access"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the code got %d %s", w.Code, w.Body.String())
+	}
+	res := &TokenResponse{}
+	decode(t, w, res)
+	claims, err := o.ParseToken(res.AccessToken)
+	if err != nil {
+		t.Fatalf("unable to parse token %s", err)
+	}
+	if len(claims.SessionID) == 0 {
+		t.Fatal("expected a session in the token of the code")
+	}
+	if err := j.Sessions.End(42, claims.SessionID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if w := refresh(o, res.RefreshToken); oauthError(t, w) != "invalid_grant" {
+		t.Errorf("expected invalid_grant for the refresh of an ended session got %s", w.Body.String())
+	}
+}
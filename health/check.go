@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Results of a check
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check verifies a single dependency of the server, a nil error
+// means the dependency is usable
+type Check func(context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of all the checks
+type Report struct {
+	Status string    `json:"status"`
+	Checks []*Result `json:"checks"`
+}
+
+// Checker runs a list of checks to decide if the server is
+// ready to accept requests
+type Checker struct {
+	status  *Status
+	timeout time.Duration
+	checks  []*namedCheck
+}
+
+// NewChecker returns a Checker that fails while the server is
+// draining, every check is given the timeout to finish
+func NewChecker(status *Status, timeout time.Duration) *Checker {
+	return &Checker{status: status, timeout: timeout}
+}
+
+// Add registers a named check
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, &namedCheck{name: name, check: check})
+}
+
+// Run runs all the checks concurrently and collects their results
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{Status: StatusOK}
+	if c.status.IsDraining() {
+		report.Status = StatusFail
+		report.Checks = append(report.Checks, &Result{
+			Name:     "shutdown",
+			Status:   StatusFail,
+			Error:    "server is shutting down",
+			Duration: "0s",
+		})
+		return report
+	}
+	results := make([]*Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, nc := range c.checks {
+		wg.Add(1)
+		go func(i int, nc *namedCheck) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			start := time.Now()
+			res := &Result{Name: nc.name, Status: StatusOK}
+			if err := nc.check(cctx); err != nil {
+				res.Status = StatusFail
+				res.Error = err.Error()
+			}
+			res.Duration = time.Since(start).String()
+			results[i] = res
+		}(i, nc)
+	}
+	wg.Wait()
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	report.Checks = results
+	return report
+}
+
+// ReadyHandler serves the readiness probe, it responds with
+// the json report of all the checks
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeReport(w, code, report)
+}
+
+// LiveHandler serves the liveness probe, it succeeds as long
+// as the process is able to serve requests
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, &Report{Status: StatusOK, Checks: []*Result{}})
+}
+
+func writeReport(w http.ResponseWriter, code int, report *Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,28 @@
+// package health keeps track of the serving state of the server
+// for the health check endpoints
+package health
+
+import "sync/atomic"
+
+// Status is the serving state of the server, it is safe for
+// concurrent use
+type Status struct {
+	draining int32
+}
+
+// NewStatus returns a Status of a server that is
+// accepting requests
+func NewStatus() *Status {
+	return &Status{}
+}
+
+// Drain marks the server as shutting down, after that the server
+// should not be considered ready for new requests
+func (s *Status) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+// IsDraining reports if the server is shutting down
+func (s *Status) IsDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
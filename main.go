@@ -2,8 +2,10 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/dictyBase/authserver/commands"
+	"github.com/dictyBase/authserver/token"
 	"github.com/dictyBase/authserver/validate"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -20,7 +22,23 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:  "log-format",
-			Usage: "Format of the log output,could be either of text or json, default is json",
+			Usage: "Format of the log output,could be either of text or json, default is text",
+		},
+		cli.StringFlag{
+			Name:   "log-level",
+			EnvVar: "LOG_LEVEL",
+			Usage:  "Minimum level of the logged messages, could be one of debug, info, warn or error",
+			Value:  "info",
+		},
+		cli.StringSliceFlag{
+			Name:  "log-redact",
+			Usage: "Query parameter whose value is redacted from the request log, could be repeated, default is code,state,token,access_token,id_token,refresh_token,client_secret",
+		},
+		cli.Float64Flag{
+			Name:   "log-authorize-sample",
+			EnvVar: "LOG_AUTHORIZE_SAMPLE",
+			Usage:  "Fraction(0 to 1) of the successful /authorize requests that are logged, failed requests are always logged",
+			Value:  1,
 		},
 	}
 	app.Commands = []cli.Command{
@@ -50,6 +68,118 @@ func main() {
 					Usage: "server port",
 					Value: 9999,
 				},
+				cli.StringFlag{
+					Name:   "store-file",
+					Usage:  "file of the embedded database for personal access tokens and sessions, they are kept in memory if not given",
+					EnvVar: "STORE_FILE",
+				},
+				cli.StringFlag{
+					Name:   "issuer-url",
+					Usage:  "public url of the server and the issuer of the id tokens, required with the oidc section of config file or clients with a jwks, it is derived from the request if not given",
+					EnvVar: "ISSUER_URL",
+				},
+				cli.IntFlag{
+					Name:   "metrics-port",
+					EnvVar: "METRICS_PORT",
+					Usage:  "port for serving the prometheus metrics",
+					Value:  9998,
+				},
+				cli.StringFlag{
+					Name:   "tracing-exporter",
+					EnvVar: "TRACING_EXPORTER",
+					Usage:  "exporter for opentelemetry spans, could be one of none, otlp or stdout",
+					Value:  "none",
+				},
+				cli.StringFlag{
+					Name:   "tracing-endpoint",
+					EnvVar: "OTEL_EXPORTER_OTLP_ENDPOINT",
+					Usage:  "address(host:port) of the otlp collector",
+					Value:  "localhost:4317",
+				},
+				cli.BoolFlag{
+					Name:   "tracing-insecure",
+					EnvVar: "TRACING_INSECURE",
+					Usage:  "connect to the otlp collector without tls",
+				},
+				cli.StringFlag{
+					Name:   "audit-file",
+					EnvVar: "AUDIT_FILE",
+					Usage:  "file for appending the audit log as json lines",
+				},
+				cli.IntFlag{
+					Name:  "audit-max-size",
+					Usage: "size in megabytes of the audit file before it is rotated",
+					Value: 100,
+				},
+				cli.IntFlag{
+					Name:  "audit-max-backups",
+					Usage: "number of rotated audit files to keep, 0 keeps all",
+				},
+				cli.IntFlag{
+					Name:  "audit-max-age",
+					Usage: "days to keep a rotated audit file, 0 keeps forever",
+				},
+				cli.BoolFlag{
+					Name:   "audit-stdout",
+					EnvVar: "AUDIT_STDOUT",
+					Usage:  "write the audit log to stdout",
+				},
+				cli.StringFlag{
+					Name:   "audit-subject",
+					EnvVar: "AUDIT_SUBJECT",
+					Usage:  "messaging subject for publishing the audit log",
+				},
+				cli.StringFlag{
+					Name:   "tls-cert",
+					EnvVar: "TLS_CERT",
+					Usage:  "certificate file for serving https",
+				},
+				cli.StringFlag{
+					Name:   "tls-key",
+					EnvVar: "TLS_KEY",
+					Usage:  "key file for serving https",
+				},
+				cli.StringFlag{
+					Name:   "tls-client-ca",
+					EnvVar: "TLS_CLIENT_CA",
+					Usage:  "certificate authority file, if given a verified client certificate is required for /authorize and /oauth/introspect",
+				},
+				cli.DurationFlag{
+					Name:  "tls-reload-interval",
+					Usage: "interval for checking the certificate and key files for changes",
+					Value: time.Minute,
+				},
+				cli.DurationFlag{
+					Name:  "read-timeout",
+					Usage: "maximum duration for reading the entire request",
+					Value: 15 * time.Second,
+				},
+				cli.DurationFlag{
+					Name:  "write-timeout",
+					Usage: "maximum duration before timing out writes of the response",
+					Value: 30 * time.Second,
+				},
+				cli.DurationFlag{
+					Name:  "idle-timeout",
+					Usage: "maximum duration to wait for the next request with keep-alives",
+					Value: 120 * time.Second,
+				},
+				cli.DurationFlag{
+					Name:  "readiness-timeout",
+					Usage: "timeout for each dependency check of the readiness probe",
+					Value: 2 * time.Second,
+				},
+				cli.DurationFlag{
+					Name:   "shutdown-timeout",
+					EnvVar: "SHUTDOWN_TIMEOUT",
+					Usage:  "grace period for in-flight requests to finish during shutdown",
+					Value:  30 * time.Second,
+				},
+				cli.DurationFlag{
+					Name:   "shutdown-delay",
+					EnvVar: "SHUTDOWN_DELAY",
+					Usage:  "duration between failing the health check and closing the listener during shutdown",
+				},
 				cli.StringFlag{
 					Name:   "messaging-host",
 					EnvVar: "NATS_SERVICE_HOST",
@@ -60,12 +190,135 @@ func main() {
 					EnvVar: "NATS_SERVICE_PORT",
 					Usage:  "port for messaging server",
 				},
+				cli.StringSliceFlag{
+					Name:   "messaging-url",
+					EnvVar: "NATS_URL",
+					Usage:  "url of messaging server, could be repeated for a cluster, takes precedence over host and port",
+				},
+				cli.BoolFlag{
+					Name:   "messaging-tls",
+					EnvVar: "NATS_TLS",
+					Usage:  "connect to messaging server with tls",
+				},
+				cli.StringFlag{
+					Name:   "messaging-tls-ca",
+					EnvVar: "NATS_TLS_CA",
+					Usage:  "certificate authority file for verifying messaging server, implies tls",
+				},
+				cli.StringFlag{
+					Name:   "messaging-tls-cert",
+					EnvVar: "NATS_TLS_CERT",
+					Usage:  "client certificate file for messaging server, implies tls",
+				},
+				cli.StringFlag{
+					Name:   "messaging-tls-key",
+					EnvVar: "NATS_TLS_KEY",
+					Usage:  "client key file for messaging server, implies tls",
+				},
+				cli.StringFlag{
+					Name:   "messaging-user",
+					EnvVar: "NATS_USER",
+					Usage:  "user for authenticating with messaging server",
+				},
+				cli.StringFlag{
+					Name:   "messaging-password",
+					EnvVar: "NATS_PASSWORD",
+					Usage:  "password for authenticating with messaging server",
+				},
+				cli.StringFlag{
+					Name:   "messaging-token",
+					EnvVar: "NATS_TOKEN",
+					Usage:  "token for authenticating with messaging server",
+				},
+				cli.StringFlag{
+					Name:   "messaging-nkey",
+					EnvVar: "NATS_NKEY",
+					Usage:  "nkey seed file for authenticating with messaging server",
+				},
+				cli.StringFlag{
+					Name:   "messaging-creds",
+					EnvVar: "NATS_CREDS",
+					Usage:  "credentials file for authenticating with messaging server",
+				},
+			},
+		},
+		{
+			Name:   "mint-token",
+			Usage:  "print a signed token for a user, meant for development and service accounts",
+			Action: commands.MintToken,
+			Before: validate.ValidateMintArgs,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "private-key, prkey",
+					Usage:  "private key file for signning jwt",
+					EnvVar: "JWT_PRIVATE_KEY",
+				},
+				cli.Int64Flag{
+					Name:  "user-id, u",
+					Usage: "dictybase user id, set as the subject of the token",
+				},
+				cli.StringFlag{
+					Name:  "email",
+					Usage: "email of the user",
+				},
+				cli.StringSliceFlag{
+					Name:  "role",
+					Usage: "role of the user, could be repeated",
+				},
+				cli.StringFlag{
+					Name:  "audience",
+					Usage: "audience of the token",
+					Value: token.DefaultAudience,
+				},
+				cli.DurationFlag{
+					Name:  "ttl",
+					Usage: "lifetime of the token",
+					Value: token.DefaultTTL,
+				},
+			},
+		},
+		{
+			Name:      "inspect-token",
+			Usage:     "decode and verify a token, reports why it would be rejected by /authorize",
+			ArgsUsage: "[token], read from stdin if not given",
+			Action:    commands.InspectToken,
+			Before:    validate.ValidateInspectArgs,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "pkey, public-key",
+					Usage:  "public key file for verifying jwt",
+					EnvVar: "JWT_PUBLIC_KEY",
+				},
+				cli.StringFlag{
+					Name:  "jwks-url",
+					Usage: "url of the json web key set for verifying jwt",
+				},
+				cli.StringFlag{
+					Name:  "algorithm",
+					Usage: "signing algorithm expected by the server, derived from the public key if not given",
+				},
+				cli.StringFlag{
+					Name:  "audience",
+					Usage: "expected audience of the token",
+					Value: token.DefaultAudience,
+				},
+				cli.StringFlag{
+					Name:  "issuer",
+					Usage: "expected issuer of the token",
+					Value: token.Issuer,
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "format of the report, could be one of text or json",
+					Value: "text",
+				},
 			},
 		},
 		{
 			Name:   "generate-keys",
-			Usage:  "generate rsa key pairs(public and private keys) in pem format",
+			Usage:  "generate rsa, ecdsa or ed25519 key pairs(public and private keys) in pem format",
 			Action: commands.GenerateKeys,
+			Before: validate.ValidateKeyArgs,
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "private, pr",
@@ -75,6 +328,33 @@ func main() {
 					Name:  "public, pub",
 					Usage: "output file name for public key",
 				},
+				cli.StringFlag{
+					Name:  "type, t",
+					Usage: "type of key, could be one of rsa, ecdsa or ed25519",
+					Value: "rsa",
+				},
+				cli.IntFlag{
+					Name:  "bits",
+					Usage: "size of rsa key, could be one of 2048, 3072 or 4096",
+					Value: 2048,
+				},
+				cli.StringFlag{
+					Name:  "curve",
+					Usage: "curve of ecdsa key, could be one of P-256 or P-384",
+					Value: "P-256",
+				},
+				cli.StringFlag{
+					Name:  "jwk",
+					Usage: "output file name for public key in jwk format",
+				},
+				cli.StringFlag{
+					Name:  "jwks",
+					Usage: "output file name for public key as a jwk set",
+				},
+				cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "overwrite existing output files",
+				},
 			},
 		},
 	}
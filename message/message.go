@@ -5,12 +5,26 @@ import (
 	"time"
 
 	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
 )
 
 type Request interface {
 	IsActive() bool
+	// Drain stops the subscriptions and waits until the pending
+	// messages are flushed and the connection is closed, or the context
+	// is done
+	Drain(context.Context) error
 	UserRequest(string, *pubsub.IdRequest, time.Duration) (*pubsub.UserReply, error)
 	UserRequestWithContext(context.Context, string, *pubsub.IdRequest) (*pubsub.UserReply, error)
 	IdentityRequest(string, *pubsub.IdentityReq, time.Duration) (*pubsub.IdentityReply, error)
 	IdentityRequestWithContext(context.Context, string, *pubsub.IdentityReq) (*pubsub.IdentityReply, error)
+	// Roles of the user with the id
+	RoleRequestWithContext(context.Context, string, *pubsub.IdRequest) (*user.RoleCollection, error)
+	// Permissions of the role with the id
+	PermissionRequestWithContext(context.Context, string, *pubsub.IdRequest) (*user.PermissionCollection, error)
+}
+
+// Publisher sends messages without waiting for any reply
+type Publisher interface {
+	Publish(string, []byte) error
 }
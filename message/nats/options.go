@@ -0,0 +1,88 @@
+package nats
+
+import (
+	"fmt"
+
+	gnats "github.com/nats-io/nats.go"
+)
+
+// Security holds the tls and authentication settings for
+// connecting to the messaging server
+type Security struct {
+	// Enable tls even if no certificate files are given, the server
+	// certificate is then verified against the system roots
+	TLS bool
+	// File with the certificate authority for verifying the server
+	CAFile string
+	// Files with the client certificate and key for mutual tls
+	CertFile string
+	KeyFile  string
+	// Credentials for user/password authentication
+	User     string
+	Password string
+	// Token for token based authentication
+	Token string
+	// File with the nkey seed for nkey authentication
+	NkeyFile string
+	// Credentials file(user jwt and nkey seed) for decentralized authentication
+	CredsFile string
+}
+
+// Validate checks that the files and credentials that belong together
+// are given together and that only one authentication method is used
+func (s *Security) Validate() error {
+	if (len(s.CertFile) == 0) != (len(s.KeyFile) == 0) {
+		return fmt.Errorf("both client certificate and key files are needed for tls")
+	}
+	auth := 0
+	if len(s.User) > 0 || len(s.Password) > 0 {
+		if len(s.User) == 0 || len(s.Password) == 0 {
+			return fmt.Errorf("both user and password are needed for authentication")
+		}
+		auth++
+	}
+	for _, v := range []string{s.Token, s.NkeyFile, s.CredsFile} {
+		if len(v) > 0 {
+			auth++
+		}
+	}
+	if auth > 1 {
+		return fmt.Errorf("only one of user/password, token, nkey or credentials file authentication is allowed")
+	}
+	return nil
+}
+
+// Options converts the security settings to a list of
+// connection options for the messaging server
+func (s *Security) Options() ([]gnats.Option, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	var opts []gnats.Option
+	if s.TLS {
+		opts = append(opts, gnats.Secure())
+	}
+	if len(s.CAFile) > 0 {
+		opts = append(opts, gnats.RootCAs(s.CAFile))
+	}
+	if len(s.CertFile) > 0 {
+		opts = append(opts, gnats.ClientCert(s.CertFile, s.KeyFile))
+	}
+	if len(s.User) > 0 {
+		opts = append(opts, gnats.UserInfo(s.User, s.Password))
+	}
+	if len(s.Token) > 0 {
+		opts = append(opts, gnats.Token(s.Token))
+	}
+	if len(s.NkeyFile) > 0 {
+		opt, err := gnats.NkeyOptionFromSeed(s.NkeyFile)
+		if err != nil {
+			return opts, fmt.Errorf("unable to read nkey seed file %s", err)
+		}
+		opts = append(opts, opt)
+	}
+	if len(s.CredsFile) > 0 {
+		opts = append(opts, gnats.UserCredentials(s.CredsFile))
+	}
+	return opts, nil
+}
@@ -0,0 +1,31 @@
+package nats
+
+import "testing"
+
+func TestSecurityValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		sec  Security
+		ok   bool
+	}{
+		{"empty", Security{}, true},
+		{"tls pair", Security{CertFile: "c.pem", KeyFile: "k.pem"}, true},
+		{"cert without key", Security{CertFile: "c.pem"}, false},
+		{"key without cert", Security{KeyFile: "k.pem"}, false},
+		{"user and password", Security{User: "u", Password: "p"}, true},
+		{"user without password", Security{User: "u"}, false},
+		{"password without user", Security{Password: "p"}, false},
+		{"token", Security{Token: "t"}, true},
+		{"token and user", Security{Token: "t", User: "u", Password: "p"}, false},
+		{"nkey and creds", Security{NkeyFile: "n", CredsFile: "c"}, false},
+	}
+	for _, tc := range cases {
+		err := tc.sec.Validate()
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
@@ -3,55 +3,134 @@ package nats
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dictyBase/authserver/message"
+	"github.com/dictyBase/authserver/tracing"
 	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
-	gnats "github.com/nats-io/go-nats"
-
-	"github.com/nats-io/go-nats/encoders/protobuf"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
+	"github.com/golang/protobuf/proto"
+	gnats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type natsRequest struct {
-	econn *gnats.EncodedConn
+	conn *gnats.Conn
 }
 
-func NewRequest(host, port string, options ...gnats.Option) (message.Request, error) {
-	nc, err := gnats.Connect(fmt.Sprintf("nats://%s:%s", host, port), options...)
-	if err != nil {
-		return &natsRequest{}, err
-	}
-	ec, err := gnats.NewEncodedConn(nc, protobuf.PROTOBUF_ENCODER)
+// NewRequest connects to the messaging servers given by the list of urls
+// and returns a request/reply client
+func NewRequest(urls []string, options ...gnats.Option) (message.Request, error) {
+	nc, err := gnats.Connect(strings.Join(urls, ","), options...)
 	if err != nil {
 		return &natsRequest{}, err
 	}
-	return &natsRequest{econn: ec}, nil
+	return &natsRequest{conn: nc}, nil
 }
 
 func (n *natsRequest) UserRequest(subj string, r *pubsub.IdRequest, timeout time.Duration) (*pubsub.UserReply, error) {
-	reply := &pubsub.UserReply{}
-	err := n.econn.Request(subj, r, reply, timeout)
-	return reply, err
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return n.UserRequestWithContext(ctx, subj, r)
 }
 
 func (n *natsRequest) UserRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*pubsub.UserReply, error) {
 	reply := &pubsub.UserReply{}
-	err := n.econn.RequestWithContext(ctx, subj, r, reply)
+	err := n.request(ctx, subj, r, reply)
 	return reply, err
 }
 
 func (n *natsRequest) IdentityRequest(subj string, r *pubsub.IdentityReq, timeout time.Duration) (*pubsub.IdentityReply, error) {
-	reply := &pubsub.IdentityReply{}
-	err := n.econn.Request(subj, r, reply, timeout)
-	return reply, err
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return n.IdentityRequestWithContext(ctx, subj, r)
 }
 
 func (n *natsRequest) IdentityRequestWithContext(ctx context.Context, subj string, r *pubsub.IdentityReq) (*pubsub.IdentityReply, error) {
 	reply := &pubsub.IdentityReply{}
-	err := n.econn.RequestWithContext(ctx, subj, r, reply)
+	err := n.request(ctx, subj, r, reply)
 	return reply, err
 }
 
+func (n *natsRequest) RoleRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*user.RoleCollection, error) {
+	reply := &user.RoleCollection{}
+	err := n.request(ctx, subj, r, reply)
+	return reply, err
+}
+
+func (n *natsRequest) PermissionRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*user.PermissionCollection, error) {
+	reply := &user.PermissionCollection{}
+	err := n.request(ctx, subj, r, reply)
+	return reply, err
+}
+
+// NewPublisher returns a Publisher that shares the connection
+// of the request client
+func NewPublisher(r message.Request) (message.Publisher, error) {
+	n, ok := r.(*natsRequest)
+	if !ok {
+		return nil, fmt.Errorf("request client is not connected to nats")
+	}
+	return n, nil
+}
+
+func (n *natsRequest) Publish(subj string, data []byte) error {
+	return n.conn.Publish(subj, data)
+}
+
 func (n *natsRequest) IsActive() bool {
-	return n.econn.Conn.IsConnected()
+	return n.conn.IsConnected()
+}
+
+// Drain starts draining the connection, which is asynchronous in nats,
+// and polls until it is closed
+func (n *natsRequest) Drain(ctx context.Context) error {
+	if err := n.conn.Drain(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for !n.conn.IsClosed() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("connection is not drained %s", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Sends a protocol buffer encoded request within a client span, the trace
+// context is propagated through the message headers if the server
+// supports them(nats-server 2.2 or later)
+func (n *natsRequest) request(ctx context.Context, subj string, req, reply proto.Message) error {
+	ctx, span := tracing.Tracer().Start(
+		ctx,
+		subj,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination", subj),
+		),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	msg := gnats.NewMsg(subj)
+	msg.Data = data
+	if n.conn.HeadersSupported() {
+		tracing.Inject(ctx, http.Header(msg.Header))
+	}
+	resp, err := n.conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return err
+	}
+	err = proto.Unmarshal(resp.Data, reply)
+	return err
 }
@@ -0,0 +1,82 @@
+package message
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Keys for looking up the subjects of the messaging topics
+const (
+	UserExists     = "userExists"
+	UserGet        = "userGet"
+	IdentityExists = "identityExists"
+	IdentityGet    = "identityGet"
+	RoleList       = "roleList"
+	PermissionList = "permissionList"
+)
+
+// Topics maps the keys used by the handlers to the subjects of the
+// messaging topics
+type Topics map[string]string
+
+// DefaultTopics returns the subjects that are served by the user,
+// identity and permission services
+func DefaultTopics() Topics {
+	return Topics{
+		UserExists:     "UserService.Exist",
+		UserGet:        "UserService.Get",
+		IdentityExists: "IdentityService.Exist",
+		IdentityGet:    "IdentityService.GetIdentity",
+		RoleList:       "RoleService.ListByUser",
+		PermissionList: "PermissionService.ListByRole",
+	}
+}
+
+// Merge returns a new set of topics where the given subjects
+// override the existing ones
+func (t Topics) Merge(subjects map[string]string) Topics {
+	nt := make(Topics)
+	for k, v := range t {
+		nt[k] = v
+	}
+	for k, v := range subjects {
+		nt[k] = v
+	}
+	return nt
+}
+
+// WithPrefix returns a new set of topics with the prefix added to all
+// the subjects
+func (t Topics) WithPrefix(prefix string) Topics {
+	nt := make(Topics)
+	for k, v := range t {
+		if len(prefix) > 0 {
+			v = fmt.Sprintf("%s.%s", strings.TrimSuffix(prefix, "."), v)
+		}
+		nt[k] = v
+	}
+	return nt
+}
+
+// Validate checks that every key known to the handlers has a subject
+// and there is no unknown key
+func (t Topics) Validate() error {
+	known := DefaultTopics()
+	var keys []string
+	for k := range known {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if len(strings.TrimSpace(t[k])) == 0 {
+			return fmt.Errorf("subject for messaging topic %s is missing", k)
+		}
+	}
+	for k := range t {
+		if _, ok := known[k]; !ok {
+			return fmt.Errorf("unknown messaging topic %s, expected one of %s", k, strings.Join(keys, ","))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package message
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	topics := DefaultTopics()
+	merged := topics.Merge(map[string]string{UserGet: "Users.Get"})
+	if merged[UserGet] != "Users.Get" {
+		t.Errorf("expected overridden subject Users.Get, got %s", merged[UserGet])
+	}
+	if merged[UserExists] != topics[UserExists] {
+		t.Errorf("expected default subject %s, got %s", topics[UserExists], merged[UserExists])
+	}
+	if topics[UserGet] != "UserService.Get" {
+		t.Errorf("merge should not change the original topics, got %s", topics[UserGet])
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	cases := []struct {
+		prefix   string
+		expected string
+	}{
+		{"", "UserService.Get"},
+		{"staging", "staging.UserService.Get"},
+		{"staging.", "staging.UserService.Get"},
+	}
+	for _, c := range cases {
+		got := DefaultTopics().WithPrefix(c.prefix)[UserGet]
+		if got != c.expected {
+			t.Errorf("prefix %q: expected %s, got %s", c.prefix, c.expected, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		topics Topics
+		err    string
+	}{
+		{"defaults", DefaultTopics(), ""},
+		{"missing subject", DefaultTopics().Merge(map[string]string{UserGet: " "}), "userGet is missing"},
+		{"unknown key", DefaultTopics().Merge(map[string]string{"userDelete": "UserService.Delete"}), "unknown messaging topic userDelete"},
+	}
+	for _, c := range cases {
+		err := c.topics.Validate()
+		switch {
+		case len(c.err) == 0 && err != nil:
+			t.Errorf("%s: unexpected error %s", c.name, err)
+		case len(c.err) > 0 && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: expected error with %q, got %v", c.name, c.err, err)
+		}
+	}
+}
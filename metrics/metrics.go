@@ -0,0 +1,174 @@
+// package metrics defines and registers the prometheus metrics
+// of the server
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dictyBase/authserver/route"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "authserver"
+
+// Outcomes of a login
+const (
+	LoginSuccess          = "success"
+	LoginIdentityNotFound = "identity_not_found"
+	LoginUserNotFound     = "user_not_found"
+	LoginProviderExchange = "provider_exchange_error"
+	LoginProviderProfile  = "provider_profile_error"
+	LoginMessagingError   = "messaging_error"
+	LoginTokenError       = "token_error"
+	LoginRoleMissing      = "role_missing"
+	LoginInvalidScope     = "invalid_scope"
+	LoginStoreError       = "store_error"
+)
+
+// Decisions of the /authorize endpoint
+const (
+	AuthorizeAllowed     = "allowed"
+	AuthorizeDenied      = "denied"
+	AuthorizePassthrough = "passthrough"
+	AuthorizeBadRequest  = "bad_request"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of http requests by route, method and status",
+		},
+		[]string{"route", "method", "status"},
+	)
+	httpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of http requests by route, method and status",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+	logins = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Number of logins by provider and outcome",
+		},
+		[]string{"provider", "outcome"},
+	)
+	providerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "provider",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of calls to the oauth providers by provider and call",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"provider", "call"},
+	)
+	messagingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "messaging",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of messaging requests by topic",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"topic"},
+	)
+	messagingErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "messaging",
+			Name:      "errors_total",
+			Help:      "Number of failed messaging requests by topic",
+		},
+		[]string{"topic"},
+	)
+	authorizeDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "authorize_decisions_total",
+			Help:      "Number of decisions made by the /authorize endpoint",
+		},
+		[]string{"decision"},
+	)
+	grants = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_grants_total",
+			Help:      "Number of requests to the oauth2 token endpoint by grant type and outcome",
+		},
+		[]string{"grant_type", "outcome"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequests,
+		httpDuration,
+		logins,
+		providerDuration,
+		messagingDuration,
+		messagingErrors,
+		authorizeDecisions,
+		grants,
+	)
+}
+
+// Handler returns the http handler that exposes the metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records the count and latency of http requests
+func Middleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		h.ServeHTTP(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"route":  route.Pattern(r),
+			"method": r.Method,
+			"status": strconv.Itoa(status),
+		}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+	return http.HandlerFunc(fn)
+}
+
+// RecordLogin counts a login attempt with the given provider and outcome
+func RecordLogin(provider, outcome string) {
+	logins.WithLabelValues(provider, outcome).Inc()
+}
+
+// ObserveProvider records the latency of a call to an oauth provider
+// that started at the given time
+func ObserveProvider(provider, call string, start time.Time) {
+	providerDuration.WithLabelValues(provider, call).Observe(time.Since(start).Seconds())
+}
+
+// RecordAuthorize counts a decision of the /authorize endpoint
+func RecordAuthorize(decision string) {
+	authorizeDecisions.WithLabelValues(decision).Inc()
+}
+
+// RecordGrant counts a request to the token endpoint, the outcome is
+// either issued or the oauth2 error code
+func RecordGrant(grantType, outcome string) {
+	grants.WithLabelValues(grantType, outcome).Inc()
+}
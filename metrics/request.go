@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/dictyBase/authserver/message"
+	"github.com/dictyBase/go-genproto/dictybaseapis/pubsub"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+type instrumentedRequest struct {
+	message.Request
+}
+
+// InstrumentRequest wraps a messaging request client to record the
+// latency and errors of every request by topic
+func InstrumentRequest(r message.Request) message.Request {
+	return &instrumentedRequest{Request: r}
+}
+
+func (i *instrumentedRequest) UserRequest(subj string, r *pubsub.IdRequest, timeout time.Duration) (*pubsub.UserReply, error) {
+	defer observeMessaging(subj, time.Now())
+	reply, err := i.Request.UserRequest(subj, r, timeout)
+	recordMessagingErr(subj, err)
+	if err == nil && reply != nil {
+		recordReplyStatus(subj, reply.Status)
+	}
+	return reply, err
+}
+
+func (i *instrumentedRequest) UserRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*pubsub.UserReply, error) {
+	defer observeMessaging(subj, time.Now())
+	reply, err := i.Request.UserRequestWithContext(ctx, subj, r)
+	recordMessagingErr(subj, err)
+	if err == nil && reply != nil {
+		recordReplyStatus(subj, reply.Status)
+	}
+	return reply, err
+}
+
+func (i *instrumentedRequest) IdentityRequest(subj string, r *pubsub.IdentityReq, timeout time.Duration) (*pubsub.IdentityReply, error) {
+	defer observeMessaging(subj, time.Now())
+	reply, err := i.Request.IdentityRequest(subj, r, timeout)
+	recordMessagingErr(subj, err)
+	if err == nil && reply != nil {
+		recordReplyStatus(subj, reply.Status)
+	}
+	return reply, err
+}
+
+func (i *instrumentedRequest) IdentityRequestWithContext(ctx context.Context, subj string, r *pubsub.IdentityReq) (*pubsub.IdentityReply, error) {
+	defer observeMessaging(subj, time.Now())
+	reply, err := i.Request.IdentityRequestWithContext(ctx, subj, r)
+	recordMessagingErr(subj, err)
+	if err == nil && reply != nil {
+		recordReplyStatus(subj, reply.Status)
+	}
+	return reply, err
+}
+
+func (i *instrumentedRequest) RoleRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*user.RoleCollection, error) {
+	defer observeMessaging(subj, time.Now())
+	reply, err := i.Request.RoleRequestWithContext(ctx, subj, r)
+	recordMessagingErr(subj, err)
+	return reply, err
+}
+
+func (i *instrumentedRequest) PermissionRequestWithContext(ctx context.Context, subj string, r *pubsub.IdRequest) (*user.PermissionCollection, error) {
+	defer observeMessaging(subj, time.Now())
+	reply, err := i.Request.PermissionRequestWithContext(ctx, subj, r)
+	recordMessagingErr(subj, err)
+	return reply, err
+}
+
+func observeMessaging(subj string, start time.Time) {
+	messagingDuration.WithLabelValues(subj).Observe(time.Since(start).Seconds())
+}
+
+func recordMessagingErr(subj string, err error) {
+	if err != nil {
+		messagingErrors.WithLabelValues(subj).Inc()
+	}
+}
+
+// a reply that failed on the other end is a messaging error as well, a
+// missing user or identity is an answer of its own and not counted
+func recordReplyStatus(subj string, st *rpcstatus.Status) {
+	if st == nil {
+		return
+	}
+	switch codes.Code(st.GetCode()) {
+	case codes.OK, codes.NotFound:
+		return
+	}
+	messagingErrors.WithLabelValues(subj).Inc()
+}
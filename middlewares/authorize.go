@@ -1,35 +1,64 @@
 package middlewares
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/metrics"
+	"github.com/dictyBase/authserver/resource"
 )
 
-func AuthorizeMiddleware(h http.Handler) http.Handler {
+// Authorizer checks the requests forwarded by the ingress before the
+// token is validated
+type Authorizer struct {
+	Auditor *audit.Auditor
+	// Resources whose GET and OPTIONS requests with a token are not
+	// passed through
+	Resources *resource.Registry
+}
+
+func (a *Authorizer) AuthorizeMiddleware(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		hdr := r.Header
-		if hdr.Get("X-Scheme") != "https" {
-			http.Error(
-				w,
-				fmt.Sprintf("scheme is %s not https", hdr.Get("X-Schema")),
-				http.StatusBadRequest,
+		if r.TLS == nil && hdr.Get("X-Scheme") != "https" {
+			metrics.RecordAuthorize(metrics.AuthorizeBadRequest)
+			ev := audit.NewEvent(r, audit.ActionAuthorize, audit.Denied)
+			ev.Reason = "request is not made over https"
+			a.Auditor.Record(ev)
+			apierror.JSONAPIError(
+				w, r,
+				apierror.ErrInsecureScheme.New("scheme is %s not https", hdr.Get("X-Scheme")),
 			)
 			return
 		}
-		if hdr.Get("X-Original-Method") == "OPTIONS" {
-			w.Write([]byte("passthrough for OPTIONS method"))
-			return
-		}
-		if hdr.Get("X-Original-Method") == "GET" {
-			w.Write([]byte("passthrough for GET method"))
-			return
+		// the token of a request to a resource is always checked for its
+		// audience, scopes and session, as the ingress forwards it
+		if !a.isProtected(r) {
+			if hdr.Get("X-Original-Method") == "OPTIONS" {
+				metrics.RecordAuthorize(metrics.AuthorizePassthrough)
+				w.Write([]byte("passthrough for OPTIONS method"))
+				return
+			}
+			if hdr.Get("X-Original-Method") == "GET" {
+				metrics.RecordAuthorize(metrics.AuthorizePassthrough)
+				w.Write([]byte("passthrough for GET method"))
+				return
+			}
 		}
 		if strings.HasPrefix(hdr.Get("X-Original-Uri"), "/tokens") {
+			metrics.RecordAuthorize(metrics.AuthorizePassthrough)
 			w.Write([]byte("no validation for /tokens"))
 			return
 		}
+		if strings.HasPrefix(hdr.Get("X-Original-Uri"), "/oauth") {
+			metrics.RecordAuthorize(metrics.AuthorizePassthrough)
+			w.Write([]byte("no validation for /oauth"))
+			return
+		}
 		if strings.HasPrefix(hdr.Get("X-Auth-Request-Redirect"), "/tokens") {
+			metrics.RecordAuthorize(metrics.AuthorizePassthrough)
 			w.Write([]byte("no validation for /tokens"))
 			return
 		}
@@ -37,3 +66,28 @@ func AuthorizeMiddleware(h http.Handler) http.Handler {
 	}
 	return http.HandlerFunc(fn)
 }
+
+// Tells if the request has a token and goes to a configured resource
+func (a *Authorizer) isProtected(r *http.Request) bool {
+	if a.Resources == nil || len(r.Header.Get("Authorization")) == 0 {
+		return false
+	}
+	_, ok := a.Resources.Match(r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Original-Uri"))
+	return ok
+}
+
+// RequireClientCert allows only the requests that are made over tls with
+// a verified client certificate
+func RequireClientCert(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			apierror.JSONAPIError(
+				w, r,
+				apierror.ErrClientCertRequired.New("a verified client certificate is required"),
+			)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
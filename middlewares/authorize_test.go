@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dictyBase/authserver/audit"
+	"github.com/dictyBase/authserver/resource"
+)
+
+func TestAuthorizeMiddlewarePassthrough(t *testing.T) {
+	a := &Authorizer{
+		Auditor: audit.NewAuditor(),
+		Resources: resource.NewRegistry([]*resource.Resource{
+			{Audience: "stock-orders", PathPrefix: "/stock/orders"},
+		}),
+	}
+	cases := []struct {
+		name      string
+		method    string
+		uri       string
+		token     string
+		validated bool
+	}{
+		{"get without resource", "GET", "/content", "Bearer xyz", false},
+		{"get of resource without token", "GET", "/stock/orders/7", "", false},
+		{"get of resource with token", "GET", "/stock/orders/7", "Bearer xyz", true},
+		{"options of resource with token", "OPTIONS", "/stock/orders", "Bearer xyz", true},
+		{"options of resource without token", "OPTIONS", "/stock/orders", "", false},
+		{"post without resource", "POST", "/content", "Bearer xyz", true},
+		{"tokens", "POST", "/tokens/google", "", false},
+	}
+	for _, c := range cases {
+		validated := false
+		h := a.AuthorizeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			validated = true
+		}))
+		r := httptest.NewRequest("POST", "/authorize", nil)
+		r.Header.Set("X-Scheme", "https")
+		r.Header.Set("X-Original-Method", c.method)
+		r.Header.Set("X-Original-Uri", c.uri)
+		if len(c.token) > 0 {
+			r.Header.Set("Authorization", c.token)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if validated != c.validated {
+			t.Errorf("%s: expected validation %t got %t", c.name, c.validated, validated)
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package middlewares
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-chi/chi/middleware"
 	"github.com/sirupsen/logrus"
 )
 
@@ -70,6 +75,34 @@ type Logger struct {
 	logStarting bool
 
 	clock timer
+
+	// query parameters whose values are not logged
+	redacted map[string]bool
+
+	// only the given fraction of successful requests under the path
+	// prefix are logged
+	samplePrefix string
+	sampleRate   float64
+}
+
+type logFieldsKey struct{}
+
+// holds the fields added by the downstream handlers
+type logFields struct {
+	mu     sync.Mutex
+	fields logrus.Fields
+}
+
+// AddLogField adds a field to the log entry of the completed request, it
+// does nothing if the request is not handled by the Logger middleware
+func AddLogField(ctx context.Context, key string, value interface{}) {
+	lf, ok := ctx.Value(logFieldsKey{}).(*logFields)
+	if !ok {
+		return
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	lf.fields[key] = value
 }
 
 // NewLogger returns a new *Logger
@@ -118,6 +151,49 @@ func (l *Logger) SetLogStarting(v bool) {
 	l.logStarting = v
 }
 
+// SetRedactedParams sets the query parameters whose values
+// are replaced in the logged request uri
+func (l *Logger) SetRedactedParams(params ...string) {
+	l.redacted = make(map[string]bool)
+	for _, p := range params {
+		l.redacted[p] = true
+	}
+}
+
+// SetSampling logs only the given fraction(0 to 1) of the successful
+// requests whose path starts with the prefix, failed requests are
+// always logged
+func (l *Logger) SetSampling(prefix string, rate float64) {
+	l.samplePrefix = prefix
+	l.sampleRate = rate
+}
+
+// Returns the request uri with the values of the redacted query parameters replaced
+func (l *Logger) requestURI(r *http.Request) string {
+	if len(l.redacted) == 0 || len(r.URL.RawQuery) == 0 {
+		return r.RequestURI
+	}
+	params := r.URL.Query()
+	for p := range params {
+		if l.redacted[p] {
+			params.Set(p, "REDACTED")
+		}
+	}
+	return fmt.Sprintf("%s?%s", r.URL.Path, params.Encode())
+}
+
+// Reports if the request is one whose success is only sampled, and
+// if so whether it is selected for logging
+func (l *Logger) sampled(r *http.Request) (bool, bool) {
+	if len(l.samplePrefix) == 0 || l.sampleRate >= 1 {
+		return false, true
+	}
+	if !strings.HasPrefix(r.URL.Path, l.samplePrefix) {
+		return false, true
+	}
+	return true, rand.Float64() < l.sampleRate
+}
+
 func (l *Logger) LoggerMiddleware(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		start := l.clock.Now()
@@ -129,25 +205,38 @@ func (l *Logger) LoggerMiddleware(h http.Handler) http.Handler {
 		}
 
 		entry := l.Logrus.WithFields(logrus.Fields{
-			"request": r.RequestURI,
+			"request": l.requestURI(r),
 			"method":  r.Method,
 			"remote":  remoteAddr,
 		})
 
-		if reqID := r.Header.Get("X-Request-Id"); reqID != "" {
+		if reqID := middleware.GetReqID(r.Context()); reqID != "" {
+			entry = entry.WithField("request_id", reqID)
+		} else if reqID := r.Header.Get("X-Request-Id"); reqID != "" {
 			entry = entry.WithField("request_id", reqID)
 		}
 
-		if l.logStarting {
+		isSampled, selected := l.sampled(r)
+		if l.logStarting && selected {
 			entry.Info("started handling request")
 		}
+		lf := &logFields{fields: logrus.Fields{}}
 		res := &LogResponseWriter{ResponseWriter: w}
-		h.ServeHTTP(res, r)
+		h.ServeHTTP(res, r.WithContext(context.WithValue(r.Context(), logFieldsKey{}, lf)))
 
+		status := res.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if isSampled && !selected && status < http.StatusBadRequest {
+			return
+		}
 		latency := l.clock.Since(start)
-		entry.WithFields(logrus.Fields{
-			"status":      res.Status(),
-			"text_status": http.StatusText(res.Status()),
+		lf.mu.Lock()
+		defer lf.mu.Unlock()
+		entry.WithFields(lf.fields).WithFields(logrus.Fields{
+			"status":      status,
+			"text_status": http.StatusText(status),
 			"took":        latency,
 			fmt.Sprintf("measure#%s.latency", l.Name): latency.Nanoseconds(),
 		}).Info("completed handling request")
@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/metrics"
 	"github.com/dictyBase/authserver/oauth2/orcid"
+	"github.com/dictyBase/authserver/tracing"
 	"github.com/dictyBase/authserver/user"
 
 	"golang.org/x/oauth2"
@@ -25,12 +29,37 @@ type ProvidersSecret struct {
 	Orcid    string `json:"orcid"`
 }
 
+// Configured returns the names of the providers that has a secret key
+func (p *ProvidersSecret) Configured() []string {
+	var names []string
+	for name, secret := range map[string]string{
+		"github":   p.Github,
+		"facebook": p.Facebook,
+		"google":   p.Google,
+		"linkedin": p.LinkedIn,
+		"orcid":    p.Orcid,
+	} {
+		if len(secret) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 type OauthConfig struct {
 	State string
 	Code  string
 	*oauth2.Config
 }
 
+// OauthConfigFromContext returns the oauth configuration that is
+// stored in the context by ParamsMiddleware
+func OauthConfigFromContext(ctx context.Context) (*OauthConfig, bool) {
+	oauthConf, ok := ctx.Value(user.ContextKeyConfig).(*OauthConfig)
+	return oauthConf, ok
+}
+
 type OauthMiddleware struct {
 	ClientSecret string
 	Endpoint     oauth2.Endpoint
@@ -43,11 +72,7 @@ func (m *OauthMiddleware) ParamsMiddleware(h http.Handler) http.Handler {
 		for _, p := range []string{"client_id", "scopes", "redirect_url", "state", "code"} {
 			v := r.FormValue(p)
 			if len(v) == 0 {
-				apherror.JSONAPIError(w,
-					apherror.ErrQueryParam.New(
-						fmt.Sprintf("missing param %q", p),
-					),
-				)
+				apierror.JSONAPIError(w, r, apierror.ErrMissingParam.New("missing param %q", p))
 				return
 			}
 		}
@@ -78,7 +103,7 @@ func (m *OauthMiddleware) OrcidMiddleware(h http.Handler) http.Handler {
 		ctx := r.Context()
 		oauthConf, ok := ctx.Value(user.ContextKeyConfig).(*OauthConfig)
 		if !ok {
-			apherror.JSONAPIError(w, apherror.ErrReqContext.New("no oauth config in request context"))
+			apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("no oauth config in request context"))
 			return
 		}
 		// Generated by curl-to-Go: https://mholt.github.io/curl-to-go
@@ -91,21 +116,28 @@ func (m *OauthMiddleware) OrcidMiddleware(h http.Handler) http.Handler {
 			oauthConf.Code,
 		)
 		body := strings.NewReader(postBody)
+		sctx, span := tracing.Tracer().Start(ctx, "orcid.exchange")
 		req, err := http.NewRequest("POST", m.Endpoint.TokenURL, body)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrOauthExchange.New("could not create client for post"))
+			tracing.End(span, err)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderExchange.New("could not create client for post"))
+			return
 		}
 		req.Header.Set("Accept", "application/json")
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		resp, err := http.DefaultClient.Do(req)
+		start := time.Now()
+		resp, err := http.DefaultClient.Do(req.WithContext(sctx))
+		tracing.End(span, err)
+		metrics.ObserveProvider("orcid", "exchange", start)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrOauthExchange.New(err.Error()))
+			metrics.RecordLogin("orcid", metrics.LoginProviderExchange)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderExchange.New("%s", err))
 			return
 		}
 		defer resp.Body.Close()
 		var orcid user.OrcidUser
 		if err := json.NewDecoder(resp.Body).Decode(&orcid); err != nil {
-			apherror.JSONAPIError(w, apherror.ErrJSONEncoding.New(err.Error()))
+			apierror.JSONAPIError(w, r, apierror.ErrProviderExchange.New("unable to decode token response %s", err))
 			return
 		}
 		u := &user.NormalizedUser{
@@ -131,25 +163,35 @@ func (m *OauthMiddleware) GoogleMiddleware(h http.Handler) http.Handler {
 		ctx := r.Context()
 		oauthConf, ok := ctx.Value(user.ContextKeyConfig).(*OauthConfig)
 		if !ok {
-			apherror.JSONAPIError(w, apherror.ErrReqContext.New("no oauth config in request context"))
+			apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("no oauth config in request context"))
 			return
 		}
 		oauthConf.Config.ClientSecret = m.ClientSecret
 		oauthConf.Config.Endpoint = m.Endpoint
-		token, err := oauthConf.Exchange(oauth2.NoContext, oauthConf.Code)
+		start := time.Now()
+		sctx, span := tracing.Tracer().Start(ctx, "google.exchange")
+		token, err := oauthConf.Exchange(sctx, oauthConf.Code)
+		tracing.End(span, err)
+		metrics.ObserveProvider("google", "exchange", start)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrOauthExchange.New(err.Error()))
+			metrics.RecordLogin("google", metrics.LoginProviderExchange)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderExchange.New("%s", err))
 			return
 		}
-		oauthClient := oauthConf.Client(oauth2.NoContext, token)
+		start = time.Now()
+		sctx, span = tracing.Tracer().Start(ctx, "google.profile")
+		oauthClient := oauthConf.Client(sctx, token)
 		resp, err := oauthClient.Get(user.Google)
+		tracing.End(span, err)
+		metrics.ObserveProvider("google", "profile", start)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrUserRetrieval.New(err.Error()))
+			metrics.RecordLogin("google", metrics.LoginProviderProfile)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderProfile.New("%s", err))
 			return
 		}
 		var google user.GoogleUser
 		if err := json.NewDecoder(resp.Body).Decode(&google); err != nil {
-			apherror.JSONAPIError(w, apherror.ErrJSONEncoding.New(err.Error()))
+			apierror.JSONAPIError(w, r, apierror.ErrProviderProfile.New("unable to decode user profile %s", err))
 			return
 		}
 		u := &user.NormalizedUser{
@@ -176,25 +218,35 @@ func (m *OauthMiddleware) FacebookMiddleware(h http.Handler) http.Handler {
 		ctx := r.Context()
 		oauthConf, ok := ctx.Value(user.ContextKeyConfig).(*OauthConfig)
 		if !ok {
-			apherror.JSONAPIError(w, apherror.ErrReqContext.New("no oauth config in request context"))
+			apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("no oauth config in request context"))
 			return
 		}
 		oauthConf.Config.ClientSecret = m.ClientSecret
 		oauthConf.Config.Endpoint = m.Endpoint
-		token, err := oauthConf.Exchange(oauth2.NoContext, oauthConf.Code)
+		start := time.Now()
+		sctx, span := tracing.Tracer().Start(ctx, "facebook.exchange")
+		token, err := oauthConf.Exchange(sctx, oauthConf.Code)
+		tracing.End(span, err)
+		metrics.ObserveProvider("facebook", "exchange", start)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrOauthExchange.New(err.Error()))
+			metrics.RecordLogin("facebook", metrics.LoginProviderExchange)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderExchange.New("%s", err))
 			return
 		}
-		oauthClient := oauthConf.Client(oauth2.NoContext, token)
+		start = time.Now()
+		sctx, span = tracing.Tracer().Start(ctx, "facebook.profile")
+		oauthClient := oauthConf.Client(sctx, token)
 		resp, err := oauthClient.Get(user.Facebook)
+		tracing.End(span, err)
+		metrics.ObserveProvider("facebook", "profile", start)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrUserRetrieval.New(err.Error()))
+			metrics.RecordLogin("facebook", metrics.LoginProviderProfile)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderProfile.New("%s", err))
 			return
 		}
 		var facebook user.GoogleUser
 		if err := json.NewDecoder(resp.Body).Decode(&facebook); err != nil {
-			apherror.JSONAPIError(w, apherror.ErrJSONEncoding.New(err.Error()))
+			apierror.JSONAPIError(w, r, apierror.ErrProviderProfile.New("unable to decode user profile %s", err))
 			return
 		}
 		u := &user.NormalizedUser{
@@ -221,25 +273,35 @@ func (m *OauthMiddleware) LinkedInMiddleware(h http.Handler) http.Handler {
 		ctx := r.Context()
 		oauthConf, ok := ctx.Value(user.ContextKeyConfig).(*OauthConfig)
 		if !ok {
-			apherror.JSONAPIError(w, apherror.ErrReqContext.New("no oauth config in request context"))
+			apierror.JSONAPIError(w, r, apierror.ErrRequestContext.New("no oauth config in request context"))
 			return
 		}
 		oauthConf.Config.ClientSecret = m.ClientSecret
 		oauthConf.Config.Endpoint = m.Endpoint
-		token, err := oauthConf.Exchange(oauth2.NoContext, oauthConf.Code)
+		start := time.Now()
+		sctx, span := tracing.Tracer().Start(ctx, "linkedin.exchange")
+		token, err := oauthConf.Exchange(sctx, oauthConf.Code)
+		tracing.End(span, err)
+		metrics.ObserveProvider("linkedin", "exchange", start)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrOauthExchange.New(err.Error()))
+			metrics.RecordLogin("linkedin", metrics.LoginProviderExchange)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderExchange.New("%s", err))
 			return
 		}
-		oauthClient := oauthConf.Client(oauth2.NoContext, token)
+		start = time.Now()
+		sctx, span = tracing.Tracer().Start(ctx, "linkedin.profile")
+		oauthClient := oauthConf.Client(sctx, token)
 		resp, err := oauthClient.Get(user.LinkedIn)
+		tracing.End(span, err)
+		metrics.ObserveProvider("linkedin", "profile", start)
 		if err != nil {
-			apherror.JSONAPIError(w, apherror.ErrUserRetrieval.New(err.Error()))
+			metrics.RecordLogin("linkedin", metrics.LoginProviderProfile)
+			apierror.JSONAPIError(w, r, apierror.ErrProviderProfile.New("%s", err))
 			return
 		}
 		var linkedin user.LinkedInUser
 		if err := json.NewDecoder(resp.Body).Decode(&linkedin); err != nil {
-			apherror.JSONAPIError(w, apherror.ErrJSONEncoding.New(err.Error()))
+			apierror.JSONAPIError(w, r, apierror.ErrProviderProfile.New("unable to decode user profile %s", err))
 			return
 		}
 		u := &user.NormalizedUser{
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/dictyBase/authserver/apierror"
+	"github.com/dictyBase/authserver/token"
+	"github.com/go-chi/jwtauth"
+)
+
+type userIDKey struct{}
+
+type actorKey struct{}
+
+// UserAuthenticator returns a middleware that allows only the requests
+// with a valid user token for one of the audiences, it should come after
+// jwtauth.Verifier. The user id from the subject of the token is stored
+// in the request context, and the admin of an impersonation token.
+func UserAuthenticator(audiences []string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			tkn, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("%s", err))
+				return
+			}
+			if tkn == nil || !tkn.Valid {
+				apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("invalid token"))
+				return
+			}
+			if iss, _ := claims["iss"].(string); iss != token.Issuer {
+				apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("token is not an access token"))
+				return
+			}
+			// the tokens narrowed to a resource could not manage the user
+			if aud, _ := claims["aud"].(string); !hasAudience(audiences, aud) {
+				apierror.JSONAPIError(w, r, apierror.ErrAudienceNotAllowed.New("token with audience %q is not allowed", aud))
+				return
+			}
+			sub, _ := claims["sub"].(string)
+			uid, err := strconv.ParseInt(sub, 10, 64)
+			if err != nil {
+				apierror.JSONAPIError(w, r, apierror.ErrInvalidToken.New("token is not issued to a user"))
+				return
+			}
+			AddLogField(r.Context(), "user_id", uid)
+			ctx := context.WithValue(r.Context(), userIDKey{}, uid)
+			if act, ok := token.ActorFromMap(claims); ok {
+				AddLogField(r.Context(), "actor", act)
+				ctx = context.WithValue(ctx, actorKey{}, act)
+			}
+			h.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func hasAudience(audiences []string, aud string) bool {
+	for _, a := range audiences {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// UserIDFromContext returns the user id that is stored by UserAuthenticator
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	uid, ok := ctx.Value(userIDKey{}).(int64)
+	return uid, ok
+}
+
+// ActorFromContext returns the admin who impersonates the user, it is
+// stored by UserAuthenticator
+func ActorFromContext(ctx context.Context) (string, bool) {
+	act, ok := ctx.Value(actorKey{}).(string)
+	return act, ok
+}
+
+// DenyImpersonation rejects the requests made with an impersonation
+// token, it should come after UserAuthenticator. It guards the endpoints
+// that issue tokens which would outlive the impersonation.
+func DenyImpersonation(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if act, ok := ActorFromContext(r.Context()); ok {
+			apierror.JSONAPIError(
+				w, r,
+				apierror.ErrImpersonationNotAllowed.New("not allowed with a token impersonated by %s", act),
+			)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/dictyBase/authserver/token"
+	"github.com/go-chi/jwtauth"
+)
+
+// Returns the status of a request with the token through the verifier
+// and UserAuthenticator, and the user id the handler got
+func authenticate(t *testing.T, key *ecdsa.PrivateKey, claims jwt.Claims) (int, int64) {
+	raw, err := token.Sign(key, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uid int64
+	h := jwtauth.Verifier(jwtauth.New("ES256", key, key.Public()))(
+		UserAuthenticator([]string{token.DefaultAudience, "web"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uid, _ = UserIDFromContext(r.Context())
+		})),
+	)
+	r := httptest.NewRequest("GET", "/users/me", nil)
+	r.Header.Set("Authorization", "BEARER "+raw)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, uid
+}
+
+func TestUserAuthenticator(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		claims jwt.Claims
+		status int
+	}{
+		{"access token", token.NewClaims(42, token.DefaultAudience, time.Hour), http.StatusOK},
+		{
+			"id token",
+			token.NewIDClaims(42, "https://auth.dictybase.org", "web", time.Now(), time.Hour),
+			http.StatusUnauthorized,
+		},
+		{"expired token", token.NewClaims(42, token.DefaultAudience, -time.Hour), http.StatusUnauthorized},
+		{"client audience", token.NewClaims(42, "web", time.Hour), http.StatusOK},
+		{"resource audience", token.NewClaims(42, "stock-orders", time.Hour), http.StatusForbidden},
+	}
+	for _, c := range cases {
+		status, uid := authenticate(t, key, c.claims)
+		if status != c.status {
+			t.Errorf("%s: expected status %d got %d", c.name, c.status, status)
+		}
+		if status == http.StatusOK && uid != 42 {
+			t.Errorf("%s: expected user id 42 got %d", c.name, uid)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// package oidc keeps the pending authorization requests, the authorization
+// codes and the refresh tokens of the openid connect authorization code
+// flow. They are kept in memory.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Scopes are the scopes of openid connect that every client could request
+var Scopes = []string{"openid", "profile", "email", "offline_access"}
+
+var (
+	// ErrNotFound is returned for an unknown or already used request or code
+	ErrNotFound = errors.New("authorization request is not found")
+	// ErrExpired is returned for a request or code past its expiry
+	ErrExpired = errors.New("authorization request is expired")
+	// ErrMismatch is returned when a code is redeemed by another client
+	// or with another redirect uri
+	ErrMismatch = errors.New("authorization code is issued for another client or redirect uri")
+	// ErrInvalidVerifier is returned when the pkce verifier does not
+	// match the challenge
+	ErrInvalidVerifier = errors.New("code verifier does not match the code challenge")
+	// ErrRefreshNotFound is returned for an unknown or already used
+	// refresh token
+	ErrRefreshNotFound = errors.New("refresh token is not found")
+	// ErrRefreshExpired is returned for a refresh token past its expiry
+	ErrRefreshExpired = errors.New("refresh token is expired")
+)
+
+// Request is an authorization request of a client that waits for the
+// user to login and approve it
+type Request struct {
+	ID          string
+	ClientID    string
+	RedirectURI string
+	Scopes      []string
+	State       string
+	Nonce       string
+	// Pkce challenge, only S256 is supported
+	CodeChallenge string
+	// User who approved the request and the time of the approval
+	UserID   int64
+	AuthTime time.Time
+	// Authorization code given to the client after approval
+	Code      string
+	ExpiresAt time.Time
+}
+
+// Store keeps the requests until they are decided and the codes until
+// they are redeemed
+type Store struct {
+	mu         sync.Mutex
+	requests   map[string]*Request
+	codes      map[string]*Request
+	requestTTL time.Duration
+	codeTTL    time.Duration
+	lastPrune  time.Time
+}
+
+// NewStore returns a Store for requests that wait for the user at most
+// requestTTL and codes that are valid for codeTTL
+func NewStore(requestTTL, codeTTL time.Duration) *Store {
+	return &Store{
+		requests:   make(map[string]*Request),
+		codes:      make(map[string]*Request),
+		requestTTL: requestTTL,
+		codeTTL:    codeTTL,
+	}
+}
+
+// Create stores the request with a new id
+func (s *Store) Create(req *Request) (*Request, error) {
+	id, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	req.ID = id
+	req.ExpiresAt = time.Now().Add(s.requestTTL)
+	s.requests[id] = req
+	c := *req
+	return &c, nil
+}
+
+// Get returns a copy of the pending request
+func (s *Store) Get(id string) (*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, err := s.pending(id)
+	if err != nil {
+		return nil, err
+	}
+	c := *req
+	return &c, nil
+}
+
+// Decide records the decision of the user for the pending request, an
+// approved request gets an authorization code
+func (s *Store) Decide(id string, userID int64, approve bool) (*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, err := s.pending(id)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.requests, id)
+	req.UserID = userID
+	if approve {
+		code, err := randomString()
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		req.Code = code
+		req.AuthTime = now
+		req.ExpiresAt = now.Add(s.codeTTL)
+		s.codes[code] = req
+	}
+	c := *req
+	return &c, nil
+}
+
+// Redeem returns the approved request of the code and removes it, so that
+// a code could be used only once. The client, the redirect uri and the
+// pkce verifier should match the request.
+func (s *Store) Redeem(code, clientID, redirectURI, verifier string) (*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, ok := s.codes[code]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.codes, code)
+	if time.Now().After(req.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	if req.ClientID != clientID || req.RedirectURI != redirectURI {
+		return nil, ErrMismatch
+	}
+	if len(req.CodeChallenge) > 0 || len(verifier) > 0 {
+		if !VerifyChallenge(req.CodeChallenge, verifier) {
+			return nil, ErrInvalidVerifier
+		}
+	}
+	return req, nil
+}
+
+func (s *Store) pending(id string) (*Request, error) {
+	req, ok := s.requests[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if time.Now().After(req.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return req, nil
+}
+
+// removes the expired requests and codes, at most once a minute
+func (s *Store) prune() {
+	now := time.Now()
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for id, req := range s.requests {
+		if now.After(req.ExpiresAt) {
+			delete(s.requests, id)
+		}
+	}
+	for code, req := range s.codes {
+		if now.After(req.ExpiresAt) {
+			delete(s.codes, code)
+		}
+	}
+}
+
+// VerifyChallenge checks the pkce verifier against the S256 challenge
+func VerifyChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// HasScope tells if the scope is in the list
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
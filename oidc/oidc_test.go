@@ -0,0 +1,120 @@
+package oidc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// example verifier and challenge of RFC 7636 appendix B
+const (
+	verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestVerifyChallenge(t *testing.T) {
+	cases := []struct {
+		name      string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{"matching verifier", challenge, verifier, true},
+		{"other verifier", challenge, strings.Replace(verifier, "d", "e", 1), false},
+		{"short verifier", challenge, verifier[:42], false},
+		{"long verifier", challenge, strings.Repeat("a", 129), false},
+		{"no challenge", "", verifier, false},
+		{"plain challenge", verifier, verifier, false},
+	}
+	for _, c := range cases {
+		if got := VerifyChallenge(c.challenge, c.verifier); got != c.want {
+			t.Errorf("%s: expected %t got %t", c.name, c.want, got)
+		}
+	}
+}
+
+// Returns the code of an approved request
+func approved(t *testing.T, s *Store, codeChallenge string) string {
+	req, err := s.Create(&Request{
+		ClientID:      "web",
+		RedirectURI:   "https://example.org/callback",
+		Scopes:        []string{"openid"},
+		CodeChallenge: codeChallenge,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = s.Decide(req.ID, 42, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req.Code
+}
+
+func TestRedeem(t *testing.T) {
+	s := NewStore(time.Minute, time.Minute)
+	cases := []struct {
+		name        string
+		challenge   string
+		clientID    string
+		redirectURI string
+		verifier    string
+		err         error
+	}{
+		{"with pkce", challenge, "web", "https://example.org/callback", verifier, nil},
+		{"without pkce", "", "web", "https://example.org/callback", "", nil},
+		{"missing verifier", challenge, "web", "https://example.org/callback", "", ErrInvalidVerifier},
+		{"verifier without challenge", "", "web", "https://example.org/callback", verifier, ErrInvalidVerifier},
+		{"other client", challenge, "cli", "https://example.org/callback", verifier, ErrMismatch},
+		{"other redirect uri", challenge, "web", "https://example.org/other", verifier, ErrMismatch},
+	}
+	for _, c := range cases {
+		code := approved(t, s, c.challenge)
+		req, err := s.Redeem(code, c.clientID, c.redirectURI, c.verifier)
+		if err != c.err {
+			t.Errorf("%s: expected %v got %v", c.name, c.err, err)
+			continue
+		}
+		if err == nil && req.UserID != 42 {
+			t.Errorf("%s: expected user 42 got %d", c.name, req.UserID)
+		}
+		// a code could be used once, even after a failure
+		if _, err := s.Redeem(code, c.clientID, c.redirectURI, c.verifier); err != ErrNotFound {
+			t.Errorf("%s: expected used code to be not found got %v", c.name, err)
+		}
+	}
+}
+
+func TestRedeemExpired(t *testing.T) {
+	s := NewStore(time.Minute, -time.Second)
+	code := approved(t, s, "")
+	if _, err := s.Redeem(code, "web", "https://example.org/callback", ""); err != ErrExpired {
+		t.Errorf("expected expired got %v", err)
+	}
+	s = NewStore(-time.Second, time.Minute)
+	req, _ := s.Create(&Request{ClientID: "web"})
+	if _, err := s.Decide(req.ID, 42, true); err != ErrExpired {
+		t.Errorf("expected expired request got %v", err)
+	}
+}
+
+func TestRefreshStore(t *testing.T) {
+	s := NewRefreshStore()
+	raw, err := s.Issue(&Grant{ClientID: "web", UserID: 42, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Use(raw, "cli"); err != ErrRefreshNotFound {
+		t.Errorf("expected not found for other client got %v", err)
+	}
+	if g, err := s.Use(raw, "web"); err != nil || g.UserID != 42 {
+		t.Errorf("expected grant of user 42 got %v %v", g, err)
+	}
+	if _, err := s.Use(raw, "web"); err != ErrRefreshNotFound {
+		t.Errorf("expected used token to be not found got %v", err)
+	}
+	raw, _ = s.Issue(&Grant{ClientID: "web", ExpiresAt: time.Now().Add(-time.Second)})
+	if _, err := s.Use(raw, "web"); err != ErrRefreshExpired {
+		t.Errorf("expected expired got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Grant is what a refresh token stands for, the user, the client and the
+// scopes that were approved. The refresh tokens of a grant belong to the
+// session that is started by the code.
+type Grant struct {
+	ClientID  string
+	UserID    int64
+	Scopes    []string
+	AuthTime  time.Time
+	SessionID string
+	ExpiresAt time.Time
+}
+
+// RefreshStore keeps the refresh tokens by their sha256 hash, a token is
+// replaced by a new one every time it is used
+type RefreshStore struct {
+	mu        sync.Mutex
+	grants    map[string]*Grant
+	lastPrune time.Time
+}
+
+// NewRefreshStore returns an empty RefreshStore
+func NewRefreshStore() *RefreshStore {
+	return &RefreshStore{grants: make(map[string]*Grant)}
+}
+
+// Issue returns a new refresh token for the grant
+func (s *RefreshStore) Issue(g *Grant) (string, error) {
+	raw, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.grants[hashToken(raw)] = g
+	return raw, nil
+}
+
+// Use returns the grant of the refresh token of the client and removes
+// the token
+func (s *RefreshStore) Use(raw, clientID string) (*Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := hashToken(raw)
+	g, ok := s.grants[h]
+	if !ok || g.ClientID != clientID {
+		return nil, ErrRefreshNotFound
+	}
+	delete(s.grants, h)
+	if time.Now().After(g.ExpiresAt) {
+		return nil, ErrRefreshExpired
+	}
+	return g, nil
+}
+
+// removes the expired tokens, at most once a minute
+func (s *RefreshStore) prune() {
+	now := time.Now()
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for h, g := range s.grants {
+		if now.After(g.ExpiresAt) {
+			delete(s.grants, h)
+		}
+	}
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
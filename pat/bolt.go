@@ -0,0 +1,114 @@
+package pat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	tokensBucket = []byte("personal_tokens")
+	// user id and token id to the hash of the token
+	usersBucket = []byte("personal_tokens_by_user")
+)
+
+// BoltStore keeps the tokens in an embedded bolt database
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore returns a BoltStore that uses the buckets of the
+// database, they are created if needed
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{tokensBucket, usersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create buckets %s", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Create(t *Token) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(tokensBucket).Put([]byte(t.Hash), data); err != nil {
+			return err
+		}
+		return tx.Bucket(usersBucket).Put(userKey(t.UserID, t.ID), []byte(t.Hash))
+	})
+}
+
+func (b *BoltStore) Get(hash string) (*Token, error) {
+	t := &Token{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(tokensBucket).Get([]byte(hash))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (b *BoltStore) List(userID int64) ([]*Token, error) {
+	var tokens []*Token
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(usersBucket).Cursor()
+		prefix := userKey(userID, "")
+		for k, hash := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, hash = c.Next() {
+			data := tx.Bucket(tokensBucket).Get(hash)
+			if data == nil {
+				continue
+			}
+			t := &Token{}
+			if err := json.Unmarshal(data, t); err != nil {
+				return err
+			}
+			tokens = append(tokens, t)
+		}
+		return nil
+	})
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens, err
+}
+
+func (b *BoltStore) Delete(userID int64, id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		key := userKey(userID, id)
+		hash := users.Get(key)
+		if hash == nil {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(tokensBucket).Delete(hash); err != nil {
+			return err
+		}
+		return users.Delete(key)
+	})
+}
+
+// Close does nothing, the database is owned by the caller
+func (b *BoltStore) Close() error {
+	return nil
+}
+
+func userKey(userID int64, id string) []byte {
+	return []byte(fmt.Sprintf("%020d/%s", userID, id))
+}
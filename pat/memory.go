@@ -0,0 +1,65 @@
+package pat
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps the tokens in memory, they are lost on restart
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tokens map[string]*Token
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: make(map[string]*Token)}
+}
+
+func (m *MemoryStore) Create(t *Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[t.Hash] = t
+	return nil
+}
+
+func (m *MemoryStore) Get(hash string) (*Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.tokens[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *MemoryStore) List(userID int64) ([]*Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tokens []*Token
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (m *MemoryStore) Delete(userID int64, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, t := range m.tokens {
+		if t.UserID == userID && t.ID == id {
+			delete(m.tokens, hash)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,149 @@
+// package pat issues and stores the personal access tokens of the users.
+// A token is the dbpat_ prefix followed by a random part and its crc32
+// checksum in base62, so that a leaked token could be detected without
+// access to the store. Only the sha256 hash of a token is stored.
+package pat
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+const (
+	// Prefix of every personal access token
+	Prefix = "dbpat_"
+	// length of the random part and of the checksum in base62
+	randomLength   = 30
+	checksumLength = 6
+	alphabet       = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+var (
+	// ErrNotFound is returned when a token is not in the store
+	ErrNotFound = errors.New("personal access token is not found")
+	// ErrMalformed is returned for a token with wrong format or checksum
+	ErrMalformed = errors.New("personal access token is malformed")
+	// ErrExpired is returned for a token past its expiry
+	ErrExpired = errors.New("personal access token is expired")
+)
+
+// Token is a stored personal access token
+type Token struct {
+	ID        string    `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IsExpired checks if the token is expired at the given time
+func (t *Token) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// Store keeps the personal access tokens
+type Store interface {
+	// Create adds a new token
+	Create(*Token) error
+	// Get returns the token with the hash
+	Get(hash string) (*Token, error)
+	// List returns all the tokens of the user
+	List(userID int64) ([]*Token, error)
+	// Delete removes the token with the id of the user
+	Delete(userID int64, id string) error
+	Close() error
+}
+
+// New generates a personal access token for the user, it returns the
+// token that is shown once to the user and its record for the store
+func New(userID int64, name string, scopes []string, ttl time.Duration) (string, *Token, error) {
+	random, err := randomString(randomLength)
+	if err != nil {
+		return "", nil, err
+	}
+	raw := Prefix + random + checksum(random)
+	now := time.Now().UTC()
+	return raw, &Token{
+		ID:        xid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      Hash(raw),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+// IsToken checks if the string looks like a personal access token, it
+// does not verify the checksum
+func IsToken(raw string) bool {
+	return strings.HasPrefix(raw, Prefix)
+}
+
+// Validate checks the format and the checksum of the token
+func Validate(raw string) error {
+	if !IsToken(raw) || len(raw) != len(Prefix)+randomLength+checksumLength {
+		return ErrMalformed
+	}
+	random := raw[len(Prefix) : len(Prefix)+randomLength]
+	if checksum(random) != raw[len(Prefix)+randomLength:] {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// Hash returns the hex encoded sha256 hash of the token
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup validates the token and returns its record if it is not expired
+func Lookup(s Store, raw string) (*Token, error) {
+	if err := Validate(raw); err != nil {
+		return nil, err
+	}
+	t, err := s.Get(Hash(raw))
+	if err != nil {
+		return nil, err
+	}
+	if t.IsExpired(time.Now()) {
+		return t, ErrExpired
+	}
+	return t, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range b {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("unable to generate random token %s", err)
+		}
+		b[i] = alphabet[v.Int64()]
+	}
+	return string(b), nil
+}
+
+// Returns the crc32 checksum of the string in base62, left padded
+// to the checksum length
+func checksum(s string) string {
+	v := big.NewInt(int64(crc32.ChecksumIEEE([]byte(s))))
+	b := []byte(v.Text(62))
+	for len(b) < checksumLength {
+		b = append([]byte{'0'}, b...)
+	}
+	return string(b)
+}
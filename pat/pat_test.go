@@ -0,0 +1,114 @@
+package pat
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestChecksum(t *testing.T) {
+	s := "abcdefghijklmnopqrstuvwxyz0123"
+	if c := checksum(s); len(c) != checksumLength || c != checksum(s) {
+		t.Fatalf("expected a stable checksum of length %d got %q", checksumLength, c)
+	}
+	if checksum(s) == checksum(s+"4") {
+		t.Error("expected different checksums for different strings")
+	}
+	if c := checksum(""); c != "000000" {
+		t.Errorf("expected zero padded checksum got %q", c)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	raw, _, err := New(42, "cli", nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := raw[len(raw)-1:]
+	changed := "0"
+	if last == "0" {
+		changed = "1"
+	}
+	cases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", raw, nil},
+		{"no prefix", strings.TrimPrefix(raw, Prefix), ErrMalformed},
+		{"short", raw[:len(raw)-1], ErrMalformed},
+		{"long", raw + "0", ErrMalformed},
+		{"wrong checksum", raw[:len(raw)-1] + changed, ErrMalformed},
+		{"wrong random part", Prefix + strings.Repeat("a", randomLength) + raw[len(raw)-checksumLength:], ErrMalformed},
+	}
+	for _, c := range cases {
+		if err := Validate(c.token); err != c.err {
+			t.Errorf("%s: expected %v got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestStores(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "pat.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bs, err := NewBoltStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]Store{"memory": NewMemoryStore(), "bolt": bs} {
+		raw, tkn, err := New(42, "cli", []string{"stocks:read"}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Create(tkn); err != nil {
+			t.Fatalf("%s: unable to create token %s", name, err)
+		}
+		_, expired, _ := New(42, "old", nil, -time.Hour)
+		if err := s.Create(expired); err != nil {
+			t.Fatalf("%s: unable to create token %s", name, err)
+		}
+		got, err := Lookup(s, raw)
+		if err != nil || got.ID != tkn.ID || got.Scopes[0] != "stocks:read" {
+			t.Errorf("%s: expected token %s got %v %v", name, tkn.ID, got, err)
+		}
+		if _, err := s.Get(expired.Hash); err != nil {
+			t.Errorf("%s: unable to get expired token %s", name, err)
+		}
+		other, _, _ := New(42, "other", nil, time.Hour)
+		if _, err := Lookup(s, other); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected not found got %v", name, err)
+		}
+		list, err := s.List(42)
+		if err != nil || len(list) != 2 {
+			t.Errorf("%s: expected 2 tokens got %d %v", name, len(list), err)
+		}
+		if list, _ := s.List(7); len(list) != 0 {
+			t.Errorf("%s: expected no tokens of other user got %d", name, len(list))
+		}
+		if err := s.Delete(7, tkn.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected not found for token of other user got %v", name, err)
+		}
+		if err := s.Delete(42, tkn.ID); err != nil {
+			t.Errorf("%s: unable to delete token %s", name, err)
+		}
+		if _, err := Lookup(s, raw); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected deleted token to be not found got %v", name, err)
+		}
+	}
+}
+
+func TestLookupExpired(t *testing.T) {
+	s := NewMemoryStore()
+	raw, tkn, _ := New(42, "old", nil, -time.Minute)
+	s.Create(tkn)
+	if _, err := Lookup(s, raw); err != ErrExpired {
+		t.Errorf("expected expired got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// package ratelimit provides a middleware that throttles requests per
+// client ip and client id and locks out clients after repeated failed logins
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dictyBase/authserver/apierror"
+)
+
+// Rule allows a number of requests within a window
+type Rule struct {
+	Requests int64
+	Window   time.Duration
+}
+
+// Lockout blocks a client for the lockout duration once it reaches the
+// maximum number of failures within the window
+type Lockout struct {
+	MaxFailures int64
+	Window      time.Duration
+	Lockout     time.Duration
+}
+
+// Options configures the limits, a nil rule is not enforced
+type Options struct {
+	PerIP        *Rule
+	PerClient    *Rule
+	FailedLogins *Lockout
+}
+
+// Limiter enforces the limits of the options
+type Limiter struct {
+	name  string
+	store Store
+	opts  *Options
+}
+
+// NewLimiter returns a Limiter, the name is used to separate the
+// counters of limiters that share a store
+func NewLimiter(name string, store Store, opts *Options) *Limiter {
+	return &Limiter{name: name, store: store, opts: opts}
+}
+
+// Middleware rejects the request with 429 if any of the limits is
+// exceeded or the client ip is locked out. The client ip is taken from the
+// remote address, so it should run after the RealIP middleware. The client
+// id is not authenticated yet, so it is counted together with the client
+// ip, otherwise anyone could use up the limit of another client. Errors
+// from the store are logged and the request is let through.
+func (l *Limiter) Middleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if l.opts.FailedLogins != nil {
+			count, reset, err := l.store.Get(l.key("lockout", ip))
+			if err != nil {
+				log.Printf("error in reading lockout of %s %s\n", ip, err)
+			} else if count > 0 {
+				tooManyRequests(w, r, reset, apierror.ErrLockedOut.New("too many failed logins from %s", ip))
+				return
+			}
+		}
+		if ok, reset := l.allow(l.opts.PerIP, l.key("ip", ip)); !ok {
+			tooManyRequests(w, r, reset, apierror.ErrRateLimited.New("request limit exceeded for %s", ip))
+			return
+		}
+		if id := r.FormValue("client_id"); len(id) > 0 {
+			if ok, reset := l.allow(l.opts.PerClient, l.key("client", ip+"/"+id)); !ok {
+				tooManyRequests(w, r, reset, apierror.ErrRateLimited.New("request limit exceeded for client %s", id))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CountFailures counts a response with status 401 or 403 as a failed
+// login of the client ip. It should only wrap the login routes, a denied
+// token elsewhere is not a failed login.
+func (l *Limiter) CountFailures(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if l.opts.FailedLogins == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		if sw.status == http.StatusUnauthorized || sw.status == http.StatusForbidden {
+			l.recordFailure(clientIP(r))
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Counts the request against the rule, it returns false with the end of
+// the window if the limit is exceeded
+func (l *Limiter) allow(rule *Rule, key string) (bool, time.Time) {
+	if rule == nil {
+		return true, time.Time{}
+	}
+	count, reset, err := l.store.Increment(key, rule.Window)
+	if err != nil {
+		log.Printf("error in counting request for %s %s\n", key, err)
+		return true, time.Time{}
+	}
+	return count <= rule.Requests, reset
+}
+
+func (l *Limiter) recordFailure(ip string) {
+	lo := l.opts.FailedLogins
+	count, _, err := l.store.Increment(l.key("failed", ip), lo.Window)
+	if err != nil {
+		log.Printf("error in counting failed login for %s %s\n", ip, err)
+		return
+	}
+	if count < lo.MaxFailures {
+		return
+	}
+	if _, _, err := l.store.Increment(l.key("lockout", ip), lo.Lockout); err != nil {
+		log.Printf("error in locking out %s %s\n", ip, err)
+		return
+	}
+	if err := l.store.Reset(l.key("failed", ip)); err != nil {
+		log.Printf("error in resetting failed logins of %s %s\n", ip, err)
+	}
+	log.Printf("locked out %s for %s after %d failed logins\n", ip, lo.Lockout, count)
+}
+
+func (l *Limiter) key(kind, value string) string {
+	return fmt.Sprintf("%s:%s:%s", l.name, kind, value)
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, reset time.Time, err *apierror.Error) {
+	secs := int64(math.Ceil(time.Until(reset).Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+	apierror.JSONAPIError(w, r, err)
+}
+
+// Returns the host part of the remote address, the RealIP middleware
+// sets it without any port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Captures the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
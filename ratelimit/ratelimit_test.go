@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func denied(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func statusOf(h http.Handler) int {
+	return statusFrom(h, "192.0.2.1:4000", "")
+}
+
+func statusFrom(h http.Handler, addr, clientID string) int {
+	r := httptest.NewRequest("POST", "/", nil)
+	r.RemoteAddr = addr
+	if len(clientID) > 0 {
+		r.URL.RawQuery = "client_id=" + clientID
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestLockout(t *testing.T) {
+	opts := &Options{FailedLogins: &Lockout{MaxFailures: 2, Window: time.Minute, Lockout: time.Minute}}
+	l := NewLimiter("tokens", NewMemoryStore(), opts)
+	h := l.Middleware(l.CountFailures(http.HandlerFunc(denied)))
+	for i := 0; i < 2; i++ {
+		if code := statusOf(h); code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 before lockout, got %d", code)
+		}
+	}
+	if code := statusOf(h); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after lockout, got %d", code)
+	}
+}
+
+func TestNoLockoutWithoutCounting(t *testing.T) {
+	opts := &Options{FailedLogins: &Lockout{MaxFailures: 2, Window: time.Minute, Lockout: time.Minute}}
+	l := NewLimiter("authorize", NewMemoryStore(), opts)
+	h := l.Middleware(http.HandlerFunc(denied))
+	for i := 0; i < 5; i++ {
+		if code := statusOf(h); code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without failure counting, got %d", code)
+		}
+	}
+}
+
+func TestPerIPLimit(t *testing.T) {
+	opts := &Options{PerIP: &Rule{Requests: 1, Window: time.Minute}}
+	l := NewLimiter("tokens", NewMemoryStore(), opts)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if code := statusOf(h); code != http.StatusOK {
+		t.Fatalf("expected 200 for the first request, got %d", code)
+	}
+	if code := statusOf(h); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 over the limit, got %d", code)
+	}
+}
+
+func TestPerClientLimit(t *testing.T) {
+	opts := &Options{PerClient: &Rule{Requests: 1, Window: time.Minute}}
+	l := NewLimiter("tokens", NewMemoryStore(), opts)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if code := statusFrom(h, "192.0.2.1:4000", "web"); code != http.StatusOK {
+		t.Fatalf("expected 200 for the first request, got %d", code)
+	}
+	if code := statusFrom(h, "192.0.2.1:4000", "web"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 over the limit, got %d", code)
+	}
+	if code := statusFrom(h, "192.0.2.2:4000", "web"); code != http.StatusOK {
+		t.Errorf("expected 200 for the same client from another ip, got %d", code)
+	}
+}
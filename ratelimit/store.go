@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps the fixed window counters of the limiter. The in-memory
+// store limits a single instance of the server, a store backed by a
+// shared database could be used to enforce the limits across instances.
+type Store interface {
+	// Increment adds one to the counter of key, a new window is started
+	// if there is none or the previous one has ended. It returns the
+	// count and the end of the current window.
+	Increment(key string, window time.Duration) (int64, time.Time, error)
+	// Get returns the count and the end of the current window of key,
+	// the count is zero if there is no active window
+	Get(key string) (int64, time.Time, error)
+	// Reset removes the counter of key
+	Reset(key string) error
+}
+
+type counter struct {
+	count int64
+	reset time.Time
+}
+
+// MemoryStore is an in-memory Store, it is safe for concurrent use
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty in-memory Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  make(map[string]*counter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *MemoryStore) Increment(key string, window time.Duration) (int64, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweep(now)
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.reset) {
+		c = &counter{reset: now.Add(window)}
+		m.counters[key] = c
+	}
+	c.count++
+	return c.count, c.reset, nil
+}
+
+func (m *MemoryStore) Get(key string) (int64, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[key]
+	if !ok || !time.Now().Before(c.reset) {
+		return 0, time.Time{}, nil
+	}
+	return c.count, c.reset, nil
+}
+
+func (m *MemoryStore) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.counters, key)
+	return nil
+}
+
+// Removes the expired counters, at most once a minute
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	for k, c := range m.counters {
+		if !now.Before(c.reset) {
+			delete(m.counters, k)
+		}
+	}
+	m.lastSweep = now
+}
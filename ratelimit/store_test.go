@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreIncrement(t *testing.T) {
+	m := NewMemoryStore()
+	for i := int64(1); i <= 3; i++ {
+		count, reset, err := m.Increment("ip:1", time.Minute)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if count != i {
+			t.Errorf("expected count %d, got %d", i, count)
+		}
+		if !reset.After(time.Now()) {
+			t.Errorf("expected reset in the future, got %s", reset)
+		}
+	}
+	if count, _, _ := m.Increment("ip:2", time.Minute); count != 1 {
+		t.Errorf("expected separate counter for another key, got %d", count)
+	}
+}
+
+func TestMemoryStoreWindow(t *testing.T) {
+	m := NewMemoryStore()
+	m.Increment("ip:1", 10*time.Millisecond)
+	m.Increment("ip:1", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if count, _, _ := m.Get("ip:1"); count != 0 {
+		t.Errorf("expected no count after the window, got %d", count)
+	}
+	if count, _, _ := m.Increment("ip:1", time.Minute); count != 1 {
+		t.Errorf("expected a new window, got count %d", count)
+	}
+}
+
+func TestMemoryStoreGetAndReset(t *testing.T) {
+	m := NewMemoryStore()
+	if count, _, _ := m.Get("ip:1"); count != 0 {
+		t.Errorf("expected zero count for unknown key, got %d", count)
+	}
+	m.Increment("ip:1", time.Minute)
+	m.Increment("ip:1", time.Minute)
+	if count, _, _ := m.Get("ip:1"); count != 2 {
+		t.Errorf("expected count 2, got %d", count)
+	}
+	if err := m.Reset("ip:1"); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if count, _, _ := m.Get("ip:1"); count != 0 {
+		t.Errorf("expected zero count after reset, got %d", count)
+	}
+}
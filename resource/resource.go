@@ -0,0 +1,103 @@
+// package resource keeps the registry of the services behind the
+// ingress, the audience and scopes their tokens should have
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is the lifetime of the tokens exchanged for a resource
+const DefaultTTL = 5 * time.Minute
+
+var (
+	// ErrAudience is returned when the token is not meant for the resource
+	ErrAudience = errors.New("token is not issued for the audience of the resource")
+	// ErrScope is returned when the token lacks a scope of the resource
+	ErrScope = errors.New("token does not have the scopes required by the resource")
+)
+
+// Resource is a service that is protected by /authorize and could be the
+// target of a token exchange
+type Resource struct {
+	// Audience the tokens of the resource should have
+	Audience string
+	// Host and path prefix of the requests to the resource, any host
+	// is matched if it is empty
+	Host       string
+	PathPrefix string
+	// Scopes that could be granted in a token exchange
+	Scopes []string
+	// Scopes that every token should have
+	RequiredScopes []string
+	// Lifetime of the exchanged tokens
+	TokenTTL time.Duration
+}
+
+// Check verifies the audience and the scopes of a token, a token without
+// any scope is denied if the resource requires scopes
+func (res *Resource) Check(audience string, scopes []string) error {
+	if audience != res.Audience {
+		return fmt.Errorf("%w, expected %s got %s", ErrAudience, res.Audience, audience)
+	}
+	return res.CheckScopes(scopes)
+}
+
+// CheckScopes verifies that every required scope is in the list
+func (res *Resource) CheckScopes(scopes []string) error {
+	for _, rs := range res.RequiredScopes {
+		if !contains(scopes, rs) {
+			return fmt.Errorf("%w, missing %s", ErrScope, rs)
+		}
+	}
+	return nil
+}
+
+// Registry looks up the resources by request and by audience
+type Registry struct {
+	resources []*Resource
+}
+
+// NewRegistry returns a registry of the resources
+func NewRegistry(resources []*Resource) *Registry {
+	return &Registry{resources: resources}
+}
+
+// Match returns the resource with the longest path prefix of the request,
+// no resource is matched for an unknown request
+func (reg *Registry) Match(host, path string) (*Resource, bool) {
+	var match *Resource
+	for _, res := range reg.resources {
+		if len(res.Host) > 0 && !strings.EqualFold(res.Host, host) {
+			continue
+		}
+		if !strings.HasPrefix(path, res.PathPrefix) {
+			continue
+		}
+		if match == nil || len(res.PathPrefix) > len(match.PathPrefix) {
+			match = res
+		}
+	}
+	return match, match != nil
+}
+
+// ByAudience returns the resource of the audience
+func (reg *Registry) ByAudience(audience string) (*Resource, bool) {
+	for _, res := range reg.resources {
+		if res.Audience == audience {
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package resource
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	reg := NewRegistry([]*Resource{
+		{Audience: "stock", PathPrefix: "/stock"},
+		{Audience: "stock-orders", Host: "api.dictybase.org", PathPrefix: "/stock/orders"},
+		{Audience: "content", PathPrefix: "/content"},
+	})
+	cases := []struct {
+		name     string
+		host     string
+		path     string
+		audience string
+	}{
+		{"prefix", "api.dictybase.org", "/stock/strains", "stock"},
+		{"longest prefix", "api.dictybase.org", "/stock/orders/7", "stock-orders"},
+		{"host without case", "API.dictybase.org", "/stock/orders/7", "stock-orders"},
+		{"other host", "dictybase.org", "/stock/orders/7", "stock"},
+		{"unknown path", "api.dictybase.org", "/users/me", ""},
+	}
+	for _, c := range cases {
+		res, ok := reg.Match(c.host, c.path)
+		switch {
+		case len(c.audience) == 0 && ok:
+			t.Errorf("%s: expected no match got %s", c.name, res.Audience)
+		case len(c.audience) > 0 && (!ok || res.Audience != c.audience):
+			t.Errorf("%s: expected %s got %v", c.name, c.audience, res)
+		}
+	}
+	if res, ok := reg.ByAudience("content"); !ok || res.PathPrefix != "/content" {
+		t.Errorf("expected content resource got %v", res)
+	}
+	if _, ok := reg.ByAudience("user"); ok {
+		t.Error("expected no resource for user audience")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	res := &Resource{Audience: "stock-orders", RequiredScopes: []string{"orders:read"}}
+	cases := []struct {
+		name     string
+		audience string
+		scopes   []string
+		err      error
+	}{
+		{"allowed", "stock-orders", []string{"orders:read", "orders:write"}, nil},
+		{"other audience", "user", []string{"orders:read"}, ErrAudience},
+		{"no scope", "stock-orders", nil, ErrScope},
+		{"other scope", "stock-orders", []string{"orders:write"}, ErrScope},
+	}
+	for _, c := range cases {
+		err := res.Check(c.audience, c.scopes)
+		if (c.err == nil && err != nil) || (c.err != nil && !errors.Is(err, c.err)) {
+			t.Errorf("%s: expected %v got %v", c.name, c.err, err)
+		}
+	}
+	open := &Resource{Audience: "content"}
+	if err := open.Check("content", nil); err != nil {
+		t.Errorf("expected token without scopes to be allowed got %s", err)
+	}
+}
@@ -0,0 +1,20 @@
+// package route gives the matched chi route of a request, which is used
+// by the metrics and the spans in place of the request path
+package route
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// Pattern returns the matched route pattern, so that the metric labels
+// and span names stay bounded irrespective of the request paths
+func Pattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || len(rctx.RoutePatterns) == 0 {
+		return "unmatched"
+	}
+	return strings.Replace(strings.Join(rctx.RoutePatterns, ""), "/*/", "/", -1)
+}
@@ -0,0 +1,224 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sessionsBucket = []byte("sessions")
+	// user id and session id to nothing, to list the sessions of a user
+	usersBucket = []byte("sessions_by_user")
+)
+
+// BoltStore keeps the sessions in an embedded bolt database
+type BoltStore struct {
+	db        *bolt.DB
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewBoltStore returns a BoltStore that uses the buckets of the
+// database, they are created if needed
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{sessionsBucket, usersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create buckets %s", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Create(s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	sweep := b.sweepDue(now)
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if sweep {
+			if err := prune(tx, now); err != nil {
+				return err
+			}
+		} else if err := pruneUser(tx, s.UserID, now); err != nil {
+			return err
+		}
+		if err := tx.Bucket(sessionsBucket).Put([]byte(s.ID), data); err != nil {
+			return err
+		}
+		return tx.Bucket(usersBucket).Put(userKey(s.UserID, s.ID), []byte{})
+	})
+}
+
+func (b *BoltStore) Get(id string) (*Session, error) {
+	var s *Session
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		s, err = get(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (b *BoltStore) List(userID int64) ([]*Session, error) {
+	var sessions []*Session
+	now := time.Now()
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(usersBucket).Cursor()
+		prefix := userKey(userID, "")
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			s, err := get(tx, string(k[len(prefix):]))
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if s.IsActive(now) {
+				sessions = append(sessions, s)
+			}
+		}
+		return nil
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, err
+}
+
+func (b *BoltStore) Touch(id string, at time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		s, err := get(tx, id)
+		if err != nil {
+			return err
+		}
+		s.LastSeenAt = at.UTC()
+		return put(tx, s)
+	})
+}
+
+func (b *BoltStore) End(userID int64, id string, at time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		s, err := get(tx, id)
+		if err != nil {
+			return err
+		}
+		if s.UserID != userID || !s.IsActive(time.Now()) {
+			return ErrNotFound
+		}
+		ended := at.UTC()
+		s.EndedAt = &ended
+		return put(tx, s)
+	})
+}
+
+// Close does nothing, the database is owned by the caller
+func (b *BoltStore) Close() error {
+	return nil
+}
+
+func get(tx *bolt.Tx, id string) (*Session, error) {
+	data := tx.Bucket(sessionsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	s := &Session{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func put(tx *bolt.Tx, s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(sessionsBucket).Put([]byte(s.ID), data)
+}
+
+// Tells if the expired sessions of all the users should be removed, at
+// most once in the prune interval
+func (b *BoltStore) sweepDue(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.lastPrune) < pruneInterval {
+		return false
+	}
+	b.lastPrune = now
+	return true
+}
+
+// removes the expired sessions of all the users
+func prune(tx *bolt.Tx, now time.Time) error {
+	var expired []*Session
+	err := tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+		s := &Session{}
+		if err := json.Unmarshal(v, s); err != nil {
+			return err
+		}
+		if !now.Before(s.ExpiresAt) {
+			expired = append(expired, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range expired {
+		if err := tx.Bucket(sessionsBucket).Delete([]byte(s.ID)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(usersBucket).Delete(userKey(s.UserID, s.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removes the expired sessions of the user, the ended ones are kept
+// until they expire
+func pruneUser(tx *bolt.Tx, userID int64, now time.Time) error {
+	users := tx.Bucket(usersBucket)
+	prefix := userKey(userID, "")
+	var expired [][]byte
+	c := users.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		s, err := get(tx, string(k[len(prefix):]))
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if s == nil || !now.Before(s.ExpiresAt) {
+			expired = append(expired, append([]byte{}, k...))
+		}
+	}
+	for _, k := range expired {
+		if err := tx.Bucket(sessionsBucket).Delete(k[len(prefix):]); err != nil {
+			return err
+		}
+		if err := users.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func userKey(userID int64, id string) []byte {
+	return []byte(fmt.Sprintf("%020d/%s", userID, id))
+}
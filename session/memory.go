@@ -0,0 +1,89 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the sessions in memory, they are lost on restart
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*Session)}
+}
+
+func (m *MemoryStore) Create(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// scanning all the sessions costs no more than scanning the ones
+	// of the user, so every expired session is removed
+	now := time.Now()
+	for id, old := range m.sessions {
+		if !now.Before(old.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+	c := *s
+	m.sessions[s.ID] = &c
+	return nil
+}
+
+func (m *MemoryStore) Get(id string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *s
+	return &c, nil
+}
+
+func (m *MemoryStore) List(userID int64) ([]*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	var sessions []*Session
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.IsActive(now) {
+			c := *s
+			sessions = append(sessions, &c)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (m *MemoryStore) Touch(id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.LastSeenAt = at.UTC()
+	return nil
+}
+
+func (m *MemoryStore) End(userID int64, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok || s.UserID != userID || !s.IsActive(time.Now()) {
+		return ErrNotFound
+	}
+	ended := at.UTC()
+	s.EndedAt = &ended
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,89 @@
+// package session keeps the login sessions of the users. Every token
+// issued at login refers to its session, so that the user could see where
+// they are logged in and end a single session.
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+var (
+	// ErrNotFound is returned when a session is not in the store
+	ErrNotFound = errors.New("session is not found")
+	// ErrEnded is returned for a session that is ended by the user
+	ErrEnded = errors.New("session is ended")
+)
+
+// Session is a login of a user on a device. It is kept until its tokens
+// expire, an ended session is kept so that its tokens are rejected.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Provider   string     `json:"provider"`
+	ClientID   string     `json:"client_id"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+}
+
+// IsActive checks if the session is neither ended nor expired at the
+// given time
+func (s *Session) IsActive(now time.Time) bool {
+	return s.EndedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// the expired sessions of all the users are removed at most once in
+// this interval
+const pruneInterval = time.Minute
+
+// Store keeps the sessions
+type Store interface {
+	// Create adds a new session, the expired sessions are removed on
+	// the way
+	Create(*Session) error
+	// Get returns the session with the id
+	Get(id string) (*Session, error)
+	// List returns the active sessions of the user
+	List(userID int64) ([]*Session, error)
+	// Touch updates the last seen time of the session
+	Touch(id string, at time.Time) error
+	// End marks the session with the id of the user as ended
+	End(userID int64, id string, at time.Time) error
+	Close() error
+}
+
+// New returns a session of the user that lasts as long as its tokens
+func New(userID int64, provider, clientID, ip, userAgent string, expiresAt time.Time) *Session {
+	now := time.Now().UTC()
+	return &Session{
+		ID:         xid.New().String(),
+		UserID:     userID,
+		Provider:   provider,
+		ClientID:   clientID,
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt.UTC(),
+	}
+}
+
+// Check returns the session with the id if it is not ended. A session
+// that is not found gives ErrNotFound, it is either expired or lost with
+// the memory store and could no longer be ended.
+func Check(s Store, id string) (*Session, error) {
+	sess, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if sess.EndedAt != nil {
+		return sess, ErrEnded
+	}
+	return sess, nil
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStores(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "session.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bs, err := NewBoltStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]Store{"memory": NewMemoryStore(), "bolt": bs} {
+		expired := New(42, "google", "web", "203.0.113.7", "curl", time.Now().Add(-time.Hour))
+		if err := s.Create(expired); err != nil {
+			t.Fatalf("%s: unable to create session %s", name, err)
+		}
+		sess := New(42, "google", "web", "203.0.113.7", "curl", time.Now().Add(time.Hour))
+		if err := s.Create(sess); err != nil {
+			t.Fatalf("%s: unable to create session %s", name, err)
+		}
+		if _, err := s.Get(expired.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected expired session to be removed got %v", name, err)
+		}
+		other := New(7, "orcid", "web", "203.0.113.8", "curl", time.Now().Add(time.Hour))
+		if err := s.Create(other); err != nil {
+			t.Fatalf("%s: unable to create session %s", name, err)
+		}
+		list, err := s.List(42)
+		if err != nil || len(list) != 1 || list[0].ID != sess.ID {
+			t.Errorf("%s: expected only the active session of the user got %v %v", name, list, err)
+		}
+		seen := time.Now().Add(time.Minute)
+		if err := s.Touch(sess.ID, seen); err != nil {
+			t.Errorf("%s: unable to touch session %s", name, err)
+		}
+		if got, _ := s.Get(sess.ID); !got.LastSeenAt.Equal(seen.UTC()) {
+			t.Errorf("%s: expected last seen at %s got %s", name, seen, got.LastSeenAt)
+		}
+		if err := s.End(7, sess.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected session of other user to be not found got %v", name, err)
+		}
+		if err := s.End(42, sess.ID, time.Now()); err != nil {
+			t.Errorf("%s: unable to end session %s", name, err)
+		}
+		if err := s.End(42, sess.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ended session to be not found got %v", name, err)
+		}
+		if list, _ := s.List(42); len(list) != 0 {
+			t.Errorf("%s: expected no active session got %d", name, len(list))
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := NewMemoryStore()
+	sess := New(42, "google", "web", "203.0.113.7", "curl", time.Now().Add(time.Hour))
+	s.Create(sess)
+	if got, err := Check(s, sess.ID); err != nil || got.ID != sess.ID {
+		t.Errorf("expected active session got %v %v", got, err)
+	}
+	if _, err := Check(s, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected unknown session to be rejected got %v", err)
+	}
+	s.End(42, sess.ID, time.Now())
+	if _, err := Check(s, sess.ID); !errors.Is(err, ErrEnded) {
+		t.Errorf("expected ended session to be rejected got %v", err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "session.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bs, err := NewBoltStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]Store{"memory": NewMemoryStore(), "bolt": bs} {
+		expired := New(42, "google", "web", "203.0.113.7", "curl", time.Now().Add(-time.Hour))
+		if err := s.Create(expired); err != nil {
+			t.Fatalf("%s: unable to create session %s", name, err)
+		}
+		// the next create of another user is due for a sweep
+		bs.lastPrune = time.Time{}
+		other := New(7, "orcid", "web", "203.0.113.8", "curl", time.Now().Add(time.Hour))
+		if err := s.Create(other); err != nil {
+			t.Fatalf("%s: unable to create session %s", name, err)
+		}
+		if _, err := s.Get(expired.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected expired session of another user to be removed got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package token
+
+import (
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// IDClaims is the claim layout of the openid connect id tokens, the
+// issuer is the public url of the server and the audience is the client
+type IDClaims struct {
+	jwt.StandardClaims
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// NewIDClaims returns the id token claims of the user for the client,
+// valid from now for the given duration
+func NewIDClaims(uid int64, issuer, clientID string, authTime time.Time, ttl time.Duration) *IDClaims {
+	now := time.Now()
+	return &IDClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    issuer,
+			Subject:   strconv.FormatInt(uid, 10),
+			Audience:  clientID,
+			ExpiresAt: now.Add(ttl).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		AuthTime: authTime.Unix(),
+	}
+}
@@ -0,0 +1,203 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Status of a check of the token
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// Check is the outcome of a single check of the token
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report is the decoded token with the outcome of every check, the
+// token is valid if none of the checks has failed and its signature is
+// verified
+type Report struct {
+	Header   map[string]interface{} `json:"header"`
+	Claims   map[string]interface{} `json:"claims"`
+	Checks   []*Check               `json:"checks"`
+	Verified bool                   `json:"verified"`
+	Valid    bool                   `json:"valid"`
+}
+
+// InspectOptions are the expectations the token is checked against
+type InspectOptions struct {
+	// Returns the key for verifying the signature, the header of the
+	// token is given for selecting the key by its kid
+	KeyFunc func(header map[string]interface{}) (interface{}, error)
+	// Algorithm the server verifies the tokens with, any of the
+	// supported ones is accepted if it is empty
+	Algorithm string
+	Audience  string
+	Issuer    string
+	// Time the token is checked at, defaults to now
+	Now time.Time
+}
+
+// Inspect decodes the token and checks it in the same way as /authorize,
+// failed checks are the reasons the token would be rejected. A mismatch
+// of the audience or the issuer fails, /authorize rejects the tokens of
+// other issuers and of other audiences than the one of the resource.
+func Inspect(raw string, opts *InspectOptions) (*Report, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token should have three segments, got %d", len(parts))
+	}
+	rep := &Report{}
+	if err := decodeSegment(parts[0], &rep.Header); err != nil {
+		return nil, fmt.Errorf("unable to decode header %s", err)
+	}
+	if err := decodeSegment(parts[1], &rep.Claims); err != nil {
+		return nil, fmt.Errorf("unable to decode claims %s", err)
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	rep.add(checkAlgorithm(rep.Header, opts.Algorithm))
+	sig := checkSignature(parts, rep.Header, opts.KeyFunc)
+	rep.Verified = sig.Status == CheckOK
+	rep.add(sig)
+	rep.add(checkExpiry(rep.Claims, now))
+	rep.add(checkNotBefore(rep.Claims, "nbf", now))
+	rep.add(checkNotBefore(rep.Claims, "iat", now))
+	rep.add(checkString(rep.Claims, "aud", opts.Audience))
+	rep.add(checkString(rep.Claims, "iss", opts.Issuer))
+	// a token whose signature is not checked could be forged
+	rep.Valid = rep.Verified
+	for _, c := range rep.Checks {
+		if c.Status == CheckFail {
+			rep.Valid = false
+		}
+	}
+	return rep, nil
+}
+
+func (r *Report) add(c *Check) {
+	r.Checks = append(r.Checks, c)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := jwt.DecodeSegment(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func checkAlgorithm(header map[string]interface{}, alg string) *Check {
+	c := &Check{Name: "algorithm", Status: CheckOK}
+	got, _ := header["alg"].(string)
+	if len(alg) == 0 {
+		switch got {
+		case "RS512", "ES256", "ES384", "EdDSA":
+			c.Message = fmt.Sprintf("signed with %s", got)
+		default:
+			c.Status = CheckFail
+			c.Message = fmt.Sprintf("token is signed with unsupported algorithm %q", got)
+		}
+		return c
+	}
+	if got != alg {
+		c.Status = CheckFail
+		c.Message = fmt.Sprintf("token is signed with %q, expected %q", got, alg)
+		return c
+	}
+	c.Message = fmt.Sprintf("signed with %s", got)
+	return c
+}
+
+func checkSignature(parts []string, header map[string]interface{}, keyFunc func(map[string]interface{}) (interface{}, error)) *Check {
+	c := &Check{Name: "signature", Status: CheckFail}
+	if keyFunc == nil {
+		c.Status = CheckWarn
+		c.Message = "no key is given, signature is not verified"
+		return c
+	}
+	alg, _ := header["alg"].(string)
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		c.Message = fmt.Sprintf("unknown signing algorithm %q", alg)
+		return c
+	}
+	key, err := keyFunc(header)
+	if err != nil {
+		c.Message = err.Error()
+		return c
+	}
+	if err := method.Verify(strings.Join(parts[0:2], "."), parts[2], key); err != nil {
+		c.Message = fmt.Sprintf("signature is invalid %s", err)
+		return c
+	}
+	c.Status = CheckOK
+	c.Message = "signature is valid"
+	return c
+}
+
+func checkExpiry(claims map[string]interface{}, now time.Time) *Check {
+	c := &Check{Name: "exp", Status: CheckOK}
+	exp, ok := numericDate(claims, "exp")
+	switch {
+	case !ok:
+		c.Status = CheckWarn
+		c.Message = "token has no expiry"
+	case now.After(exp):
+		c.Status = CheckFail
+		c.Message = fmt.Sprintf("token expired at %s, %s ago", exp.Format(time.RFC3339), now.Sub(exp).Round(time.Second))
+	default:
+		c.Message = fmt.Sprintf("expires at %s, in %s", exp.Format(time.RFC3339), exp.Sub(now).Round(time.Second))
+	}
+	return c
+}
+
+func checkNotBefore(claims map[string]interface{}, name string, now time.Time) *Check {
+	c := &Check{Name: name, Status: CheckOK}
+	t, ok := numericDate(claims, name)
+	switch {
+	case !ok:
+		c.Message = "not given"
+	case now.Before(t):
+		c.Status = CheckFail
+		c.Message = fmt.Sprintf("token is not valid before %s, %s from now", t.Format(time.RFC3339), t.Sub(now).Round(time.Second))
+	default:
+		c.Message = t.Format(time.RFC3339)
+	}
+	return c
+}
+
+func checkString(claims map[string]interface{}, name, expected string) *Check {
+	c := &Check{Name: name, Status: CheckOK}
+	got, _ := claims[name].(string)
+	switch {
+	case len(expected) == 0:
+		c.Message = fmt.Sprintf("%q, not checked", got)
+	case got != expected:
+		c.Status = CheckFail
+		c.Message = fmt.Sprintf("token has %q, expected %q", got, expected)
+	default:
+		c.Message = got
+	}
+	return c
+}
+
+func numericDate(claims map[string]interface{}, name string) (time.Time, bool) {
+	v, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
@@ -0,0 +1,92 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+func TestInspectSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := Sign(key, NewClaims(42, DefaultAudience, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cases := []struct {
+		name     string
+		key      interface{}
+		verified bool
+		valid    bool
+	}{
+		{"no key", nil, false, false},
+		{"signing key", key.Public(), true, true},
+		{"other key", other.Public(), false, false},
+	}
+	for _, c := range cases {
+		opts := &InspectOptions{}
+		if c.key != nil {
+			k := c.key
+			opts.KeyFunc = func(map[string]interface{}) (interface{}, error) { return k, nil }
+		}
+		rep, err := Inspect(raw, opts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if rep.Verified != c.verified || rep.Valid != c.valid {
+			t.Errorf("%s: expected verified %t and valid %t, got %t and %t", c.name, c.verified, c.valid, rep.Verified, rep.Valid)
+		}
+	}
+}
+
+func TestInspectExpired(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	raw, _ := Sign(key, NewClaims(42, DefaultAudience, time.Hour))
+	rep, err := Inspect(raw, &InspectOptions{
+		KeyFunc: func(map[string]interface{}) (interface{}, error) { return key.Public(), nil },
+		Now:     time.Now().Add(2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Valid {
+		t.Error("expected an expired token to be invalid")
+	}
+}
+
+func TestInspectClaims(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := Sign(key, NewClaims(42, "stock-orders", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFunc := func(map[string]interface{}) (interface{}, error) { return key.Public(), nil }
+	cases := []struct {
+		name     string
+		audience string
+		issuer   string
+		valid    bool
+	}{
+		{"not checked", "", "", true},
+		{"matching", "stock-orders", Issuer, true},
+		{"other audience", "user", Issuer, false},
+		{"other issuer", "stock-orders", "https://auth.dictybase.org", false},
+	}
+	for _, c := range cases {
+		rep, err := Inspect(raw, &InspectOptions{KeyFunc: keyFunc, Audience: c.audience, Issuer: c.issuer})
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if rep.Valid != c.valid {
+			t.Errorf("%s: expected valid %t got %t", c.name, c.valid, rep.Valid)
+		}
+	}
+}
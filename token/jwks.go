@@ -0,0 +1,196 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// JWK is a public key in the json web key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// rsa keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// ecdsa and ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a set of json web keys
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// NewJWK converts a rsa, ecdsa or ed25519 public key to a json web
+// key for signing, the kid is the thumbprint of the key
+func NewJWK(pub crypto.PublicKey) (*JWK, error) {
+	alg, err := Algorithm(pub)
+	if err != nil {
+		return nil, err
+	}
+	k := &JWK{Use: "sig", Alg: alg}
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		k.Kty = "RSA"
+		k.N = encodeBigInt(p.N, 0)
+		k.E = encodeBigInt(big.NewInt(int64(p.E)), 0)
+	case *ecdsa.PublicKey:
+		size := (p.Curve.Params().BitSize + 7) / 8
+		k.Kty = "EC"
+		k.Crv = p.Curve.Params().Name
+		k.X = encodeBigInt(p.X, size)
+		k.Y = encodeBigInt(p.Y, size)
+	case ed25519.PublicKey:
+		k.Kty = "OKP"
+		k.Crv = "Ed25519"
+		k.X = base64.RawURLEncoding.EncodeToString(p)
+	}
+	k.Kid, err = k.Thumbprint()
+	return k, err
+}
+
+// KeyID returns the kid of the public key, the thumbprint of its
+// json web key
+func KeyID(pub crypto.PublicKey) (string, error) {
+	k, err := NewJWK(pub)
+	if err != nil {
+		return "", err
+	}
+	return k.Kid, nil
+}
+
+// Thumbprint returns the base64url encoded sha256 thumbprint of the
+// key as defined in RFC 7638
+func (k *JWK) Thumbprint() (string, error) {
+	var members string
+	switch k.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, k.E, k.Kty, k.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k.Crv, k.Kty, k.X, k.Y)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, k.Crv, k.Kty, k.X)
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// FetchJWKS downloads the key set from the url
+func FetchJWKS(url string) (*JWKS, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch key set %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch key set, got status %s", res.Status)
+	}
+	set := &JWKS{}
+	if err := json.NewDecoder(res.Body).Decode(set); err != nil {
+		return nil, fmt.Errorf("unable to decode key set %s", err)
+	}
+	return set, nil
+}
+
+// Key returns the key with the kid, without a kid the only key
+// of the set is returned
+func (s *JWKS) Key(kid string) (*JWK, error) {
+	if len(kid) == 0 {
+		if len(s.Keys) == 1 {
+			return s.Keys[0], nil
+		}
+		return nil, fmt.Errorf("token has no kid and the key set has %d keys", len(s.Keys))
+	}
+	for _, k := range s.Keys {
+		if k.Kid == kid {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("no key with kid %q in the key set", kid)
+}
+
+// PublicKey converts the json web key to a public key
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus %s", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent %s", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate %s", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate %s", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %s", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// Encodes the integer in base64url, left padded with zeros to size bytes
+func encodeBigInt(n *big.Int, size int) string {
+	b := n.Bytes()
+	if len(b) < size {
+		b = append(make([]byte, size-len(b)), b...)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
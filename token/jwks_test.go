@@ -0,0 +1,80 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestThumbprint(t *testing.T) {
+	// example key of RFC 7638 section 3.1
+	k := &JWK{
+		Kty: "RSA",
+		E:   "AQAB",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W" +
+			"-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbIS" +
+			"D08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		// members other than the required ones are ignored
+		Kid: "2011-04-29",
+		Alg: "RS256",
+	}
+	tp, err := k.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; tp != want {
+		t.Fatalf("expected thumbprint %s got %s", want, tp)
+	}
+	if _, err := (&JWK{Kty: "oct"}).Thumbprint(); err == nil {
+		t.Fatal("expected error for unsupported key type")
+	}
+}
+
+func TestNewJWK(t *testing.T) {
+	rkey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ekey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		key  interface{}
+		kty  string
+		alg  string
+	}{
+		{"rsa", &rkey.PublicKey, "RSA", "RS512"},
+		{"ecdsa", &ekey.PublicKey, "EC", "ES256"},
+		{"ed25519", pub, "OKP", "EdDSA"},
+	}
+	for _, c := range cases {
+		k, err := NewJWK(c.key)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		if k.Kty != c.kty || k.Alg != c.alg {
+			t.Errorf("%s: expected %s %s got %s %s", c.name, c.kty, c.alg, k.Kty, k.Alg)
+		}
+		tp, err := k.Thumbprint()
+		if err != nil || k.Kid != tp {
+			t.Errorf("%s: expected kid to be the thumbprint %s got %s", c.name, tp, k.Kid)
+		}
+		back, err := k.PublicKey()
+		if err != nil {
+			t.Fatalf("%s: unable to convert back %s", c.name, err)
+		}
+		kid, err := KeyID(back)
+		if err != nil || kid != k.Kid {
+			t.Errorf("%s: expected the same kid after a round trip got %s", c.name, kid)
+		}
+	}
+}
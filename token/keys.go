@@ -0,0 +1,84 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ParsePrivateKey parses a pem encoded rsa, ecdsa or ed25519 private key,
+// in pkcs8, pkcs1(rsa) or sec1(ecdsa) format
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block in private key")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	if _, err := Algorithm(signer.Public()); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// ParsePublicKey parses a pem encoded rsa, ecdsa or ed25519 public key,
+// in pkix or pkcs1(rsa) format
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block in public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		if pkey, perr := x509.ParsePKCS1PublicKey(block.Bytes); perr == nil {
+			return pkey, nil
+		}
+		return nil, err
+	}
+	if _, err := Algorithm(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Algorithm returns the jwt signing algorithm for the key, RS512 for
+// rsa, ES256 or ES384 for ecdsa depending on the curve and EdDSA for
+// ed25519. Both the private and public keys are accepted.
+func Algorithm(key interface{}) (string, error) {
+	if s, ok := key.(crypto.Signer); ok {
+		key = s.Public()
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS512.Alg(), nil
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().Name {
+		case "P-256":
+			return jwt.SigningMethodES256.Alg(), nil
+		case "P-384":
+			return jwt.SigningMethodES384.Alg(), nil
+		}
+		return "", fmt.Errorf("unsupported ecdsa curve %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return SigningMethodEdDSA.Alg(), nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
@@ -0,0 +1,149 @@
+// package token builds and signs the jwt tokens issued by the server
+package token
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/rs/xid"
+)
+
+const (
+	// Issuer of all the tokens
+	Issuer = "dictyBase"
+	// DefaultAudience is the audience of the tokens issued at login
+	DefaultAudience = "user"
+	// DefaultTTL is the lifetime of the tokens issued at login
+	DefaultTTL = time.Hour * 240
+)
+
+// ServicePrefix is prepended to the client id in the subject of
+// the tokens issued to services
+const ServicePrefix = "service:"
+
+// Actor is the party that acts on behalf of the subject of a token,
+// the act claim of RFC 8693
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// Claims is the claim layout of the tokens, the standard claims
+// with optional email, roles and permissions of the user. Tokens of
+// services have the client id and the granted scopes.
+type Claims struct {
+	jwt.StandardClaims
+	Email       string   `json:"email,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// Version of the permissions when they are too many for the token
+	PermissionsVersion string `json:"perm_ver,omitempty"`
+	ClientID           string `json:"client_id,omitempty"`
+	// Space separated list of granted scopes
+	Scope string `json:"scope,omitempty"`
+	// Admin who impersonates the user
+	Actor *Actor `json:"act,omitempty"`
+	// Login session of the token
+	SessionID string `json:"sid,omitempty"`
+}
+
+// ActorFromMap returns the subject of the act claim of the decoded
+// claims of a token
+func ActorFromMap(claims map[string]interface{}) (string, bool) {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	sub, ok := act["sub"].(string)
+	return sub, ok && len(sub) > 0
+}
+
+// NewClaims returns the claims for the user id with a unique token id,
+// valid from now for the given duration
+func NewClaims(uid int64, audience string, ttl time.Duration) *Claims {
+	return newClaims(strconv.FormatInt(uid, 10), audience, ttl)
+}
+
+// NewServiceClaims returns the claims for a service that is
+// identified by its client id
+func NewServiceClaims(clientID, audience string, ttl time.Duration) *Claims {
+	c := newClaims(ServicePrefix+clientID, audience, ttl)
+	c.ClientID = clientID
+	return c
+}
+
+func newClaims(subject, audience string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    Issuer,
+			Subject:   subject,
+			ExpiresAt: now.Add(ttl).Unix(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			Id:        xid.New().String(),
+			Audience:  audience,
+		},
+	}
+}
+
+// Exchange returns a copy of the claims for another audience with a new
+// token id, it is valid for the given duration but never beyond the
+// expiry of the original claims
+func (c *Claims) Exchange(audience string, ttl time.Duration) *Claims {
+	now := time.Now()
+	ex := *c
+	ex.Id = xid.New().String()
+	ex.Audience = audience
+	ex.IssuedAt = now.Unix()
+	ex.NotBefore = now.Unix()
+	ex.ExpiresAt = now.Add(ttl).Unix()
+	if c.ExpiresAt > 0 && c.ExpiresAt < ex.ExpiresAt {
+		ex.ExpiresAt = c.ExpiresAt
+	}
+	return &ex
+}
+
+// SetPermissions adds the permissions to the claims if their total length
+// is within maxSize, otherwise only their version is added so that the
+// services look them up. A maxSize of zero has no limit.
+func (c *Claims) SetPermissions(perms []string, maxSize int) {
+	size := 0
+	for _, p := range perms {
+		size += len(p) + 3
+	}
+	if maxSize > 0 && size > maxSize {
+		c.Permissions = nil
+		c.PermissionsVersion = PermissionsVersion(perms)
+		return
+	}
+	c.Permissions = perms
+	c.PermissionsVersion = ""
+}
+
+// PermissionsVersion returns a short hash of the sorted permissions, it
+// changes whenever the permissions change
+func PermissionsVersion(perms []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(perms, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign signs the claims with the private key, the algorithm is chosen from
+// the type of key and the kid of the public key is set in the header
+func Sign(key crypto.Signer, claims jwt.Claims) (string, error) {
+	alg, err := Algorithm(key)
+	if err != nil {
+		return "", err
+	}
+	kid, err := KeyID(key.Public())
+	if err != nil {
+		return "", err
+	}
+	t := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	t.Header["kid"] = kid
+	return t.SignedString(key)
+}
@@ -0,0 +1,30 @@
+package token
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExchange(t *testing.T) {
+	claims := NewClaims(42, DefaultAudience, time.Hour)
+	claims.Roles = []string{"curator"}
+	claims.SessionID = "session"
+	ex := claims.Exchange("stock-orders", 5*time.Minute)
+	if ex.Id == claims.Id {
+		t.Error("expected a new token id")
+	}
+	if ex.Audience != "stock-orders" || claims.Audience != DefaultAudience {
+		t.Errorf("expected audience of the copy only to change got %s and %s", ex.Audience, claims.Audience)
+	}
+	if ex.Subject != claims.Subject || ex.Roles[0] != "curator" || ex.SessionID != "session" {
+		t.Errorf("expected subject, roles and session to be kept got %v", ex)
+	}
+	if d := time.Until(time.Unix(ex.ExpiresAt, 0)); d > 5*time.Minute || d < 4*time.Minute {
+		t.Errorf("expected expiry in 5m got %s", d)
+	}
+	short := NewClaims(42, DefaultAudience, time.Minute)
+	ex = short.Exchange("stock-orders", time.Hour)
+	if ex.ExpiresAt != short.ExpiresAt {
+		t.Errorf("expected expiry not beyond the original %d got %d", short.ExpiresAt, ex.ExpiresAt)
+	}
+}
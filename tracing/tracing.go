@@ -0,0 +1,144 @@
+// package tracing sets up the opentelemetry tracing of the server
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/dictyBase/authserver/route"
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/dictyBase/authserver"
+
+// Supported span exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Options configures the export of spans
+type Options struct {
+	// One of none, otlp or stdout
+	Exporter string
+	// Address(host:port) of the otlp collector
+	Endpoint string
+	// Disables tls for the otlp collector
+	Insecure bool
+}
+
+// Init sets up the global tracer provider and propagator from the options.
+// The returned function flushes and stops the exporter, it should be
+// called before the program exits. With no exporter spans are not
+// recorded but the trace context is still propagated.
+func Init(opts *Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(
+		propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		),
+	)
+	noop := func(context.Context) error { return nil }
+	var exp sdktrace.SpanExporter
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return noop, nil
+	case ExporterStdout:
+		e, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return noop, fmt.Errorf("unable to create stdout exporter %s", err)
+		}
+		exp = e
+	case ExporterOTLP:
+		eopts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+		if opts.Insecure {
+			eopts = append(eopts, otlptracegrpc.WithInsecure())
+		}
+		e, err := otlptracegrpc.New(context.Background(), eopts...)
+		if err != nil {
+			return noop, fmt.Errorf("unable to create otlp exporter %s", err)
+		}
+		exp = e
+	default:
+		return noop, fmt.Errorf("unknown tracing exporter %s", opts.Exporter)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(
+			resource.NewSchemaless(attribute.String("service.name", "authserver")),
+		),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the tracer for creating spans of the server
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// End records the error, if any, and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject adds the trace context of ctx to the headers of an
+// outgoing message
+func Inject(ctx context.Context, hdr http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(hdr))
+}
+
+// Middleware starts a server span for every http request, continuing
+// any trace context sent by the client. The span is named after the
+// matched route.
+func Middleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(
+			r.Context(),
+			propagation.HeaderCarrier(r.Header),
+		)
+		ctx, span := Tracer().Start(
+			ctx,
+			r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.Path),
+			),
+		)
+		defer span.End()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		h.ServeHTTP(ww, r.WithContext(ctx))
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		pattern := route.Pattern(r)
+		span.SetName(fmt.Sprintf("%s %s", r.Method, pattern))
+		span.SetAttributes(
+			attribute.String("http.route", pattern),
+			attribute.Int("http.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+	}
+	return http.HandlerFunc(fn)
+}
@@ -2,6 +2,9 @@ package validate
 
 import (
 	"fmt"
+	"net/url"
+
+	"github.com/dictyBase/authserver/message/nats"
 
 	"gopkg.in/urfave/cli.v1"
 )
@@ -11,8 +14,6 @@ func ValidateRunArgs(c *cli.Context) error {
 		"config",
 		"public-key",
 		"private-key",
-		"messaging-host",
-		"messaging-port",
 	} {
 		if len(c.String(p)) == 0 {
 			return cli.NewExitError(
@@ -21,5 +22,134 @@ func ValidateRunArgs(c *cli.Context) error {
 			)
 		}
 	}
+	if err := validateMessagingArgs(c); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	if err := validateTLSArgs(c); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	if r := c.GlobalFloat64("log-authorize-sample"); r < 0 || r > 1 {
+		return cli.NewExitError("argument log-authorize-sample should be between 0 and 1", 2)
+	}
+	switch c.GlobalString("log-format") {
+	case "json", "text":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unknown log format %s", c.GlobalString("log-format")),
+			2,
+		)
+	}
+	if u := c.String("issuer-url"); len(u) > 0 {
+		pu, err := url.Parse(u)
+		if err != nil || len(pu.Scheme) == 0 || len(pu.Host) == 0 {
+			return cli.NewExitError("argument issuer-url should be an absolute url", 2)
+		}
+	}
+	switch c.String("tracing-exporter") {
+	case "none", "otlp", "stdout":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unknown tracing exporter %s", c.String("tracing-exporter")),
+			2,
+		)
+	}
+	return nil
+}
+
+func ValidateMintArgs(c *cli.Context) error {
+	if len(c.String("private-key")) == 0 {
+		return cli.NewExitError("argument private-key is missing", 2)
+	}
+	if c.Int64("user-id") <= 0 {
+		return cli.NewExitError("argument user-id is missing", 2)
+	}
+	if len(c.String("audience")) == 0 {
+		return cli.NewExitError("argument audience is missing", 2)
+	}
+	if c.Duration("ttl") <= 0 {
+		return cli.NewExitError("argument ttl should be positive", 2)
+	}
+	return nil
+}
+
+func ValidateInspectArgs(c *cli.Context) error {
+	if len(c.String("public-key")) > 0 && len(c.String("jwks-url")) > 0 {
+		return cli.NewExitError("only one of public-key or jwks-url arguments is allowed", 2)
+	}
+	switch c.String("format") {
+	case "text", "json":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unknown report format %s", c.String("format")),
+			2,
+		)
+	}
+	return nil
+}
+
+func ValidateKeyArgs(c *cli.Context) error {
+	if !c.IsSet("public") {
+		return cli.NewExitError("public key output file is not provided", 2)
+	}
+	if !c.IsSet("private") {
+		return cli.NewExitError("private key output file is not provided", 2)
+	}
+	switch c.String("type") {
+	case "rsa":
+		switch c.Int("bits") {
+		case 2048, 3072, 4096:
+		default:
+			return cli.NewExitError(fmt.Sprintf("unsupported rsa key size %d", c.Int("bits")), 2)
+		}
+	case "ecdsa":
+		switch c.String("curve") {
+		case "P-256", "P-384":
+		default:
+			return cli.NewExitError(fmt.Sprintf("unsupported ecdsa curve %s", c.String("curve")), 2)
+		}
+	case "ed25519":
+	default:
+		return cli.NewExitError(fmt.Sprintf("unknown key type %s", c.String("type")), 2)
+	}
 	return nil
 }
+
+func validateTLSArgs(c *cli.Context) error {
+	if (len(c.String("tls-cert")) == 0) != (len(c.String("tls-key")) == 0) {
+		return fmt.Errorf("arguments tls-cert and tls-key has to be given together")
+	}
+	if len(c.String("tls-client-ca")) > 0 && len(c.String("tls-cert")) == 0 {
+		return fmt.Errorf("argument tls-client-ca needs tls-cert and tls-key")
+	}
+	return nil
+}
+
+func validateMessagingArgs(c *cli.Context) error {
+	if len(c.StringSlice("messaging-url")) == 0 {
+		for _, p := range []string{"messaging-host", "messaging-port"} {
+			if len(c.String(p)) == 0 {
+				return fmt.Errorf("argument %s or messaging-url is missing", p)
+			}
+		}
+	}
+	if err := MessagingSecurity(c).Validate(); err != nil {
+		return fmt.Errorf("error in messaging arguments %s", err)
+	}
+	return nil
+}
+
+// MessagingSecurity returns the tls and authentication settings of the
+// messaging server from the messaging flags
+func MessagingSecurity(c *cli.Context) *nats.Security {
+	return &nats.Security{
+		TLS:       c.Bool("messaging-tls"),
+		CAFile:    c.String("messaging-tls-ca"),
+		CertFile:  c.String("messaging-tls-cert"),
+		KeyFile:   c.String("messaging-tls-key"),
+		User:      c.String("messaging-user"),
+		Password:  c.String("messaging-password"),
+		Token:     c.String("messaging-token"),
+		NkeyFile:  c.String("messaging-nkey"),
+		CredsFile: c.String("messaging-creds"),
+	}
+}